@@ -17,8 +17,10 @@ package v1alpha1
 import (
 	"fmt"
 	"hash/adler32"
+	"reflect"
 	"strconv"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/json"
 )
@@ -46,6 +48,11 @@ type AddonParams struct {
 	// Data values that will be parameters injected into workflows
 	// +optional
 	Data map[string]FlexString `json:"data,omitempty"`
+	// SecretParams are the param keys whose values are sensitive, e.g. tokens, the values the data, the package params
+	// or a paramsFromConfigMap have for them are replaced with *** in the logs and events of the addon. Workflows
+	// still receive the values.
+	// +optional
+	SecretParams []string `json:"secretParams,omitempty"`
 }
 
 // FlexString is a ptr to string type that is used to provide additional configs
@@ -133,7 +140,7 @@ const (
 	random
 )
 
-// ApplicationAssemblyPhase tracks the Addon CRD phases: pending, succeeded, failed, deleting, deleteFailed
+// ApplicationAssemblyPhase tracks the Addon CRD phases: pending, running, succeeded, failed, deleting, deleteFailed
 type ApplicationAssemblyPhase string
 
 // Constants
@@ -141,6 +148,9 @@ const (
 	// Used to indicate that not all of application's components
 	// have been deployed yet.
 	Pending ApplicationAssemblyPhase = "Pending"
+	// Used to indicate that the application's components
+	// are actively being deployed.
+	Running ApplicationAssemblyPhase = "Running"
 	// Used to indicate that all of application's components
 	// have already been deployed.
 	Succeeded ApplicationAssemblyPhase = "Succeeded"
@@ -196,6 +206,9 @@ type SecretCmdSpec struct {
 	Name string   `json:"name"`
 	Cmd  CmdType  `json:"cmd,omitempty"`
 	Args []string `json:"args,omitempty" protobuf:"bytes,4,rep,name=args"`
+	// Items selects the keys of the secret to project when it is mounted into a workflow, unset mounts all keys
+	// +optional
+	Items []corev1.KeyToPath `json:"items,omitempty"`
 }
 
 // WorkflowType allows user to specify workflow templates with optional namePrefix, workflowRole or role.
@@ -204,14 +217,234 @@ type WorkflowType struct {
 	// +kubebuilder:validation:MaxLength=10
 	// +optional
 	NamePrefix string `json:"namePrefix,omitempty"`
-	// Role used to denote the role annotation that should be used by the deployment resource
+	// NameTemplate is a go template the workflow name is rendered from, e.g. "{{.PkgName}}-{{.Type}}-{{.PkgVersion}}".
+	// The placeholders are PkgName, PkgVersion, Type and Checksum, the result is sanitized to a DNS-1123 label. The
+	// addon checksum is appended to the name when the template doesn't use it, so a changed addon is resubmitted.
+	// +optional
+	NameTemplate string `json:"nameTemplate,omitempty"`
+	// NameStrategy is how the workflow is named, Explicit uses the name given to install the workflow and Generate has
+	// the API server generate a unique name. Unset uses the given name if there is one. Delete workflows are looked up
+	// by name so they can't use Generate.
+	// +kubebuilder:validation:Enum=Explicit;Generate
+	// +optional
+	NameStrategy NameStrategy `json:"nameStrategy,omitempty"`
+	// Role used to denote the role annotation that should be used by the deployment resource, it is also used
+	// as the workflow serviceAccountName when the template does not specify one
 	// +optional
 	Role string `json:"role,omitempty"`
 	// WorkflowRole used to denote the role annotation that should be used by the workflow
 	// +optional
 	WorkflowRole string `json:"workflowRole,omitempty"`
 	// Template is used to provide the workflow spec
-	Template string `json:"template"`
+	// +optional
+	Template string `json:"template,omitempty"`
+	// TemplateRef names an argo WorkflowTemplate, as name or namespace/name, to run instead of an embedded template
+	// +optional
+	TemplateRef string `json:"templateRef,omitempty"`
+	// TTLSecondsAfterCompletion limits the lifetime of a workflow that has finished execution
+	// +optional
+	TTLSecondsAfterCompletion *int32 `json:"ttlSecondsAfterCompletion,omitempty"`
+	// ActiveDeadlineSeconds is the duration a workflow may run before it is failed, defaults to 1 hour
+	// +optional
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+	// ImagePullSecrets are the names of secrets used to pull the workflow images
+	// +optional
+	ImagePullSecrets []string `json:"imagePullSecrets,omitempty"`
+	// RetryStrategy lets argo retry failed workflow steps
+	// +optional
+	RetryStrategy *RetryStrategy `json:"retryStrategy,omitempty"`
+	// Parallelism limits the number of workflow pods that run at the same time
+	// +optional
+	Parallelism *int64 `json:"parallelism,omitempty"`
+	// SuspendOnStart submits the workflow suspended so it only runs once it is resumed, e.g. after a manual approval
+	// +optional
+	SuspendOnStart bool `json:"suspendOnStart,omitempty"`
+	// PriorityClassName is the priority class of the workflow pods
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// NodeSelector pins the workflow pods to nodes with matching labels
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Tolerations let the workflow pods schedule onto tainted nodes
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// Affinity holds the node, pod affinity and anti-affinity rules of the workflow pods
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+	// ArchiveLogs has argo archive the workflow logs to the artifact repository, unset uses the argo controller default
+	// +optional
+	ArchiveLogs *bool `json:"archiveLogs,omitempty"`
+	// PodResources are the resource requests and limits of workflow steps that don't set their own
+	// +optional
+	PodResources *corev1.ResourceRequirements `json:"podResources,omitempty"`
+	// Env are environment variables added to the workflow steps, variables set by the template take precedence
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+	// ArtifactRepositoryRef selects the artifact repository config of the workflow, unset uses the argo default
+	// +optional
+	ArtifactRepositoryRef *ArtifactRepositoryRef `json:"artifactRepositoryRef,omitempty"`
+	// Metrics are prometheus metrics emitted by argo for the workflow
+	// +optional
+	Metrics []WorkflowMetric `json:"metrics,omitempty"`
+	// PodGCStrategy is when argo deletes the workflow pods, one of OnPodCompletion, OnPodSuccess, OnWorkflowCompletion
+	// or OnWorkflowSuccess
+	// +kubebuilder:validation:Enum=OnPodCompletion;OnPodSuccess;OnWorkflowCompletion;OnWorkflowSuccess
+	// +optional
+	PodGCStrategy string `json:"podGCStrategy,omitempty"`
+	// Volumes are added to the workflow volumes, e.g. a shared cache, template volumes with the same name take
+	// precedence
+	// +optional
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+	// VolumeMounts are added to the container and script templates of the workflow, unless the template already uses
+	// the name or mount path
+	// +optional
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+	// MountSecrets mounts the addon secrets read-only into the container and script templates of the workflow under
+	// /etc/addon/secrets/<name>, the secrets must exist in the workflow namespace
+	// +optional
+	MountSecrets bool `json:"mountSecrets,omitempty"`
+	// ImagePullPolicy overrides the image pull policy of the container and script templates of the workflow, one of
+	// Always, IfNotPresent or Never
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	// +optional
+	ImagePullPolicy string `json:"imagePullPolicy,omitempty"`
+	// SuspendDuration resumes the suspend steps of the workflow after the duration, e.g. "30m", so an install that is not
+	// approved in time proceeds. A workflow without suspend steps is gated by one before its entrypoint.
+	// +optional
+	SuspendDuration string `json:"suspendDuration,omitempty"`
+	// ParamsFromConfigMap adds the keys of the config map as global workflow parameters, addon params take precedence
+	// +optional
+	ParamsFromConfigMap *ConfigMapRef `json:"paramsFromConfigMap,omitempty"`
+	// PodSpecPatch is a JSON or YAML patch of the workflow pod specs, e.g. to set a restricted security context. Fields
+	// of the template podSpecPatch take precedence.
+	// +optional
+	PodSpecPatch string `json:"podSpecPatch,omitempty"`
+	// Preconditions are API resource types that must be served by the cluster, the workflow is held in pending until
+	// they are
+	// +optional
+	Preconditions []APIPrecondition `json:"preconditions,omitempty"`
+	// PodAnnotations are added to the annotations of the workflow pods, e.g. for workload identity, annotations of the
+	// template pod metadata take precedence
+	// +optional
+	PodAnnotations map[string]string `json:"podAnnotations,omitempty"`
+	// SecurityContext is set as the pod security context of the workflow, e.g. to run as non root with an fsGroup, a
+	// template security context takes precedence
+	// +optional
+	SecurityContext *corev1.PodSecurityContext `json:"securityContext,omitempty"`
+	// TemplateDefaults is a JSON or YAML argo template applied as the default of every workflow template, e.g. a
+	// timeout or retryStrategy. Fields of the template templateDefaults take precedence.
+	// +optional
+	TemplateDefaults string `json:"templateDefaults,omitempty"`
+	// OnExitTemplate names the workflow template argo runs as the exit handler once the workflow completes, whether it
+	// succeeded or failed, e.g. to clean up or notify
+	// +optional
+	OnExitTemplate string `json:"onExitTemplate,omitempty"`
+	// InitContainers are added to the init containers of the container and script templates of the workflow, e.g. to
+	// wait on a service, template init containers with the same name take precedence
+	// +optional
+	InitContainers []corev1.Container `json:"initContainers,omitempty"`
+	// Synchronization has the workflow wait on an argo semaphore or mutex, e.g. to limit how many addons install at once
+	// +optional
+	Synchronization *Synchronization `json:"synchronization,omitempty"`
+	// HostAliases are added to the /etc/hosts file of the workflow pods, e.g. for air-gapped clusters without DNS for
+	// the package registries
+	// +optional
+	HostAliases []corev1.HostAlias `json:"hostAliases,omitempty"`
+	// WaitForDependents holds the delete workflow in pending until no other addon depends on the addon package
+	// +optional
+	WaitForDependents bool `json:"waitForDependents,omitempty"`
+	// WaitForDependencies holds the workflow in pending until all package dependencies are installed
+	// +optional
+	WaitForDependencies bool `json:"waitForDependencies,omitempty"`
+}
+
+// NameStrategy is how the workflow name is chosen
+type NameStrategy string
+
+const (
+	// ExplicitName submits the workflow with the name it is installed with
+	ExplicitName NameStrategy = "Explicit"
+	// GenerateName submits the workflow with a name generated by the API server
+	GenerateName NameStrategy = "Generate"
+)
+
+// RetryStrategy configures how argo retries failed workflow steps
+type RetryStrategy struct {
+	// Limit is the maximum number of times a step is retried
+	// +kubebuilder:validation:Minimum=0
+	Limit int32 `json:"limit"`
+	// RetryPolicy is one of Always, OnFailure or OnError
+	// +optional
+	RetryPolicy string `json:"retryPolicy,omitempty"`
+	// Backoff delays retries
+	// +optional
+	Backoff *RetryBackoff `json:"backoff,omitempty"`
+}
+
+// RetryBackoff is the delay between step retries
+type RetryBackoff struct {
+	// Duration is the base delay before the first retry, e.g. "10s"
+	Duration string `json:"duration,omitempty"`
+	// Factor multiplies the delay after each retry
+	Factor int32 `json:"factor,omitempty"`
+	// MaxDuration caps the total time spent retrying, e.g. "5m"
+	MaxDuration string `json:"maxDuration,omitempty"`
+}
+
+// ArtifactRepositoryRef references an artifact repository config stored in a config map
+type ArtifactRepositoryRef struct {
+	// ConfigMap is the name of the config map holding the artifact repository config
+	ConfigMap string `json:"configMap"`
+	// Key is the key of the artifact repository config in the config map
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// APIPrecondition is an API resource type identified by group, version and kind
+type APIPrecondition struct {
+	// Group of the resource type, empty for the core API group
+	// +optional
+	Group string `json:"group,omitempty"`
+	// Version of the resource type
+	Version string `json:"version"`
+	// Kind of the resource type
+	Kind string `json:"kind"`
+}
+
+// ConfigMapRef references a config map by namespace and name
+type ConfigMapRef struct {
+	// Namespace of the config map, defaults to the addon namespace
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Name of the config map
+	Name string `json:"name"`
+}
+
+// Synchronization limits how many workflows run at once, either Semaphore or Mutex is set
+type Synchronization struct {
+	// Semaphore references the config map key holding how many workflows can hold the semaphore at once
+	// +optional
+	Semaphore *corev1.ConfigMapKeySelector `json:"semaphore,omitempty"`
+	// Mutex is the name of a mutex only one workflow can hold at a time
+	// +optional
+	Mutex string `json:"mutex,omitempty"`
+}
+
+// WorkflowMetric describes a prometheus gauge or counter emitted by argo for a workflow
+type WorkflowMetric struct {
+	// Name of the metric
+	Name string `json:"name"`
+	// Help describes the metric
+	Help string `json:"help"`
+	// Type is one of gauge or counter
+	// +kubebuilder:validation:Enum=gauge;counter
+	Type string `json:"type"`
+	// Value of the metric, defaults to the workflow duration for a gauge and 1 for a counter
+	// +optional
+	Value string `json:"value,omitempty"`
+	// Labels are added to the metric
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 // LifecycleWorkflowSpec is where all of the lifecycle workflow templates will be specified under
@@ -230,6 +463,9 @@ type PackageSpec struct {
 	PkgType        PackageType       `json:"pkgType"`
 	PkgDescription string            `json:"pkgDescription"`
 	PkgDeps        map[string]string `json:"pkgDeps,omitempty"`
+	// PkgParams are package values that will be injected as global workflow parameters
+	// +optional
+	PkgParams map[string]string `json:"pkgParams,omitempty"`
 }
 
 // AddonSpec defines the desired state of Addon
@@ -274,6 +510,35 @@ type ObjectStatus struct {
 	Status string `json:"status,omitempty"`
 }
 
+// ConditionType is the type of an addon condition
+type ConditionType string
+
+const (
+	// ConditionInstalled is True once the install workflow has succeeded
+	ConditionInstalled ConditionType = "Installed"
+	// ConditionProgressing is True while a workflow is still running
+	ConditionProgressing ConditionType = "Progressing"
+	// ConditionDegraded is True when a workflow failed or reported a warning
+	ConditionDegraded ConditionType = "Degraded"
+)
+
+// Condition is an observation of the addon state, it follows the shape of the upstream metav1.Condition
+type Condition struct {
+	// Type of condition
+	Type ConditionType `json:"type"`
+	// Status of the condition, one of True, False, Unknown
+	Status corev1.ConditionStatus `json:"status"`
+	// LastTransitionTime is the last time the condition changed status
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Reason is a CamelCase reason for the last transition
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Message is a human readable description of the last transition
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
 // AddonStatus defines the observed state of Addon
 type AddonStatus struct {
 	Checksum  string               `json:"checksum"`
@@ -281,6 +546,11 @@ type AddonStatus struct {
 	Resources []ObjectStatus       `json:"resources"`
 	Reason    string               `json:"reason"`
 	StartTime int64                `json:"starttime,omitempty"`
+	// Workflows are the names of the workflows last submitted for each lifecycle step
+	// +optional
+	Workflows map[LifecycleStep]string `json:"workflows,omitempty"`
+	// +optional
+	Conditions []Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -324,6 +594,7 @@ func (a *Addon) GetPackageSpec() PackageSpec {
 		PkgChannel:     a.Spec.PkgChannel,
 		PkgDescription: a.Spec.PkgDescription,
 		PkgType:        a.Spec.PkgType,
+		PkgParams:      a.Spec.PkgParams,
 	}
 }
 
@@ -333,6 +604,9 @@ func (a *Addon) GetAllAddonParameters() map[string]string {
 	params["namespace"] = a.Spec.Params.Namespace
 	params["clusterName"] = a.Spec.Params.Context.ClusterName
 	params["clusterRegion"] = a.Spec.Params.Context.ClusterRegion
+	for k, v := range a.Spec.PkgParams {
+		params[k] = v
+	}
 	for k, v := range a.Spec.Params.Context.AdditionalConfigs {
 		params[k] = string(v)
 	}
@@ -376,9 +650,152 @@ func (a *Addon) GetFormattedWorkflowName(lifecycleStep LifecycleStep) string {
 	return wfIdentifierName
 }
 
-// CalculateChecksum converts the AddonSpec into a hash string (using Alder32 algo)
+// CalculateChecksum converts the AddonSpec into a hash string (using Alder32 algo). Workflow names include the
+// checksum, so the spec fields that predate the workflow type options are formatted as they always were and addons
+// that don't use the newer fields keep their checksum across upgrades. The newer fields that are set are appended as
+// json, formatting them would print pointer fields as addresses.
 func (a *Addon) CalculateChecksum() string {
-	return fmt.Sprintf("%x", adler32.Checksum([]byte(fmt.Sprintf("%+v", a.Spec))))
+	data := fmt.Sprintf("%+v", newLegacyChecksumSpec(&a.Spec))
+	if ext := checksumExtensions(&a.Spec); ext != nil {
+		data += string(ext)
+	}
+	return fmt.Sprintf("%x", adler32.Checksum([]byte(data)))
+}
+
+// legacyChecksumSpec mirrors the AddonSpec fields, names and order the checksum was originally calculated from
+type legacyChecksumSpec struct {
+	PackageSpec legacyChecksumPackageSpec
+	Params      legacyChecksumParams
+	Selector    metav1.LabelSelector
+	Overrides   AddonOverridesSpec
+	Secrets     []legacyChecksumSecret
+	Lifecycle   legacyChecksumLifecycle
+}
+
+type legacyChecksumPackageSpec struct {
+	PkgChannel     string
+	PkgName        string
+	PkgVersion     string
+	PkgType        PackageType
+	PkgDescription string
+	PkgDeps        map[string]string
+}
+
+type legacyChecksumParams struct {
+	Namespace string
+	Context   ClusterContext
+	Data      map[string]FlexString
+}
+
+type legacyChecksumSecret struct {
+	Name string
+	Cmd  CmdType
+	Args []string
+}
+
+type legacyChecksumLifecycle struct {
+	Prereqs  legacyChecksumWorkflowType
+	Install  legacyChecksumWorkflowType
+	Delete   legacyChecksumWorkflowType
+	Validate legacyChecksumWorkflowType
+}
+
+type legacyChecksumWorkflowType struct {
+	NamePrefix   string
+	Role         string
+	WorkflowRole string
+	Template     string
+}
+
+func newLegacyChecksumSpec(spec *AddonSpec) legacyChecksumSpec {
+	legacy := legacyChecksumSpec{
+		PackageSpec: legacyChecksumPackageSpec{
+			PkgChannel:     spec.PkgChannel,
+			PkgName:        spec.PkgName,
+			PkgVersion:     spec.PkgVersion,
+			PkgType:        spec.PkgType,
+			PkgDescription: spec.PkgDescription,
+			PkgDeps:        spec.PkgDeps,
+		},
+		Params: legacyChecksumParams{
+			Namespace: spec.Params.Namespace,
+			Context:   spec.Params.Context,
+			Data:      spec.Params.Data,
+		},
+		Selector:  spec.Selector,
+		Overrides: spec.Overrides,
+		Lifecycle: legacyChecksumLifecycle{
+			Prereqs:  newLegacyChecksumWorkflowType(&spec.Lifecycle.Prereqs),
+			Install:  newLegacyChecksumWorkflowType(&spec.Lifecycle.Install),
+			Delete:   newLegacyChecksumWorkflowType(&spec.Lifecycle.Delete),
+			Validate: newLegacyChecksumWorkflowType(&spec.Lifecycle.Validate),
+		},
+	}
+	if spec.Secrets != nil {
+		legacy.Secrets = make([]legacyChecksumSecret, len(spec.Secrets))
+		for i, secret := range spec.Secrets {
+			legacy.Secrets[i] = legacyChecksumSecret{Name: secret.Name, Cmd: secret.Cmd, Args: secret.Args}
+		}
+	}
+
+	return legacy
+}
+
+func newLegacyChecksumWorkflowType(wt *WorkflowType) legacyChecksumWorkflowType {
+	return legacyChecksumWorkflowType{
+		NamePrefix:   wt.NamePrefix,
+		Role:         wt.Role,
+		WorkflowRole: wt.WorkflowRole,
+		Template:     wt.Template,
+	}
+}
+
+// checksumExtensions returns the json encoding of the spec fields that are not part of legacyChecksumSpec, nil if
+// none of them are set. Fields added to the spec outside of WorkflowType have to be added here to change the checksum.
+func checksumExtensions(spec *AddonSpec) []byte {
+	ext := struct {
+		PkgParams    map[string]string               `json:"pkgParams,omitempty"`
+		SecretParams []string                        `json:"secretParams,omitempty"`
+		SecretItems  map[string][]corev1.KeyToPath   `json:"secretItems,omitempty"`
+		Lifecycle    map[LifecycleStep]*WorkflowType `json:"lifecycle,omitempty"`
+	}{
+		PkgParams:    spec.PkgParams,
+		SecretParams: spec.Params.SecretParams,
+	}
+
+	for _, secret := range spec.Secrets {
+		if len(secret.Items) > 0 {
+			if ext.SecretItems == nil {
+				ext.SecretItems = make(map[string][]corev1.KeyToPath)
+			}
+			ext.SecretItems[secret.Name] = secret.Items
+		}
+	}
+
+	steps := map[LifecycleStep]*WorkflowType{
+		Prereqs:  &spec.Lifecycle.Prereqs,
+		Install:  &spec.Lifecycle.Install,
+		Delete:   &spec.Lifecycle.Delete,
+		Validate: &spec.Lifecycle.Validate,
+	}
+	for step, wt := range steps {
+		// Every WorkflowType field but the legacy ones is an extension
+		options := wt.DeepCopy()
+		options.NamePrefix, options.Role, options.WorkflowRole, options.Template = "", "", "", ""
+		if !reflect.DeepEqual(options, &WorkflowType{}) {
+			if ext.Lifecycle == nil {
+				ext.Lifecycle = make(map[LifecycleStep]*WorkflowType)
+			}
+			ext.Lifecycle[step] = options
+		}
+	}
+
+	if len(ext.PkgParams) == 0 && len(ext.SecretParams) == 0 && ext.SecretItems == nil && ext.Lifecycle == nil {
+		return nil
+	}
+
+	data, _ := json.Marshal(ext)
+	return data
 }
 
 // GetInstallStatus returns the install phase for addon
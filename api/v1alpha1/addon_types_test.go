@@ -16,6 +16,8 @@ package v1alpha1
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -168,7 +170,7 @@ var _ = Describe("Addon", func() {
 			// Update status checksum
 			fetched.Status.Checksum = checksum
 
-			wfName := fetched.GetFormattedWorkflowName(&fetched.Spec.Lifecycle.Install, "install")
+			wfName := fetched.GetFormattedWorkflowName(Install)
 			Expect(wfName).To(Equal(fmt.Sprintf("foo-install-%s-wf", checksum)))
 
 			By("updating labels")
@@ -186,4 +188,88 @@ var _ = Describe("Addon", func() {
 
 	})
 
+	Context("Checksum", func() {
+
+		It("should change with every spec field", func() {
+			// Fields missing from the legacy checksum spec and the checksum extensions wouldn't change workflow names
+			base := (&Addon{}).CalculateChecksum()
+			for _, path := range specFieldPaths(reflect.TypeOf(AddonSpec{}), "") {
+				a := &Addon{}
+				setSpecField(reflect.ValueOf(&a.Spec).Elem(), strings.Split(path, "."))
+				Expect(a.CalculateChecksum()).NotTo(Equal(base), path)
+			}
+		})
+
+	})
+
 })
+
+// specFieldPaths returns the dot separated paths of the fields of t, the fields of the structs of this package are
+// walked into, slices of them count as their element
+func specFieldPaths(t reflect.Type, prefix string) []string {
+	var paths []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		path := prefix + f.Name
+		ft := f.Type
+		if ft.Kind() == reflect.Slice {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && ft.PkgPath() == t.PkgPath() {
+			paths = append(paths, specFieldPaths(ft, path+".")...)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// setSpecField sets the field at path to a non-zero value, slices on the way get one element
+func setSpecField(v reflect.Value, path []string) {
+	if v.Kind() == reflect.Slice {
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		v = v.Index(0)
+	}
+	if len(path) == 0 {
+		setNonZero(v)
+		return
+	}
+	setSpecField(v.FieldByName(path[0]), path[1:])
+}
+
+func setNonZero(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString("x")
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1)
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		setNonZero(v.Elem())
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		setNonZero(v.Index(0))
+	case reflect.Map:
+		v.Set(reflect.MakeMap(v.Type()))
+		key := reflect.New(v.Type().Key()).Elem()
+		setNonZero(key)
+		v.SetMapIndex(key, reflect.New(v.Type().Elem()).Elem())
+	case reflect.Struct:
+		// One set field makes the struct non-zero
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				setNonZero(v.Field(i))
+				return
+			}
+		}
+	}
+}
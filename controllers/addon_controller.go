@@ -73,11 +73,12 @@ type AddonReconciler struct {
 	dynClient       dynamic.Interface
 	generatedClient *kubernetes.Clientset
 	recorder        record.EventRecorder
+	wfl             workflows.AddonLifecycle
 }
 
 // NewAddonReconciler returns an instance of AddonReconciler
 func NewAddonReconciler(mgr manager.Manager, log logr.Logger) *AddonReconciler {
-	return &AddonReconciler{
+	r := &AddonReconciler{
 		Client:          mgr.GetClient(),
 		Log:             log,
 		Scheme:          mgr.GetScheme(),
@@ -86,6 +87,10 @@ func NewAddonReconciler(mgr manager.Manager, log logr.Logger) *AddonReconciler {
 		generatedClient: kubernetes.NewForConfigOrDie(mgr.GetConfig()),
 		recorder:        mgr.GetEventRecorderFor("addons"),
 	}
+	// The workflow lifecycle is shared by the reconciles, the addon is passed to every call
+	r.wfl = workflows.NewWorkflowLifecycleWithOptions(r.Client, r.dynClient, r.recorder, r.Scheme,
+		workflows.WithLogger(log.WithName("workflows")), workflows.WithDiscoveryClient(r.generatedClient.Discovery()))
+	return r
 }
 
 // +kubebuilder:rbac:groups=addonmgr.keikoproj.io,resources=addons,verbs=get;list;watch;create;update;patch;delete
@@ -235,8 +240,6 @@ func (r *AddonReconciler) processAddon(ctx context.Context, req reconcile.Reques
 		return reconcile.Result{}, err
 	}
 
-	var wfl = workflows.NewWorkflowLifecycle(r.Client, r.dynClient, instance, r.recorder, r.Scheme)
-
 	// Resource is being deleted, run finalizers and exit.
 	if !instance.ObjectMeta.DeletionTimestamp.IsZero() {
 		// For a better user experience we want to update the status and requeue
@@ -246,7 +249,7 @@ func (r *AddonReconciler) processAddon(ctx context.Context, req reconcile.Reques
 			return reconcile.Result{Requeue: true}, nil
 		}
 
-		err := r.Finalize(ctx, instance, wfl, finalizerName)
+		err := r.Finalize(ctx, instance, finalizerName)
 		if err != nil {
 			reason := fmt.Sprintf("Addon %s/%s could not be finalized. %v", instance.Namespace, instance.Name, err)
 			r.recorder.Event(instance, "Warning", "Failed", reason)
@@ -291,7 +294,7 @@ func (r *AddonReconciler) processAddon(ctx context.Context, req reconcile.Reques
 	//r.addAddonToCache(req, addon, addonmgrv1alpha1.Pending)
 
 	// Prereqs workflow
-	prereqsPhase, err := r.runWorkflow(addonmgrv1alpha1.Prereqs, instance, wfl)
+	prereqsPhase, err := r.runWorkflow(addonmgrv1alpha1.Prereqs, instance)
 	instance.Status.Lifecycle.Prereqs = prereqsPhase
 	if err != nil {
 		reason := fmt.Sprintf("Addon %s/%s prereqs failed. %v", instance.Namespace, instance.Name, err)
@@ -331,7 +334,7 @@ func (r *AddonReconciler) processAddon(ctx context.Context, req reconcile.Reques
 			return reconcile.Result{}, err
 		}
 
-		phase, err := r.runWorkflow(addonmgrv1alpha1.Install, instance, wfl)
+		phase, err := r.runWorkflow(addonmgrv1alpha1.Install, instance)
 		instance.Status.Lifecycle.Installed = phase
 		if err != nil {
 			reason := fmt.Sprintf("Addon %s/%s could not be installed due to error. %v", instance.Namespace, instance.Name, err)
@@ -373,7 +376,7 @@ func ignoreNotFound(err error) error {
 	return err
 }
 
-func (r *AddonReconciler) runWorkflow(lifecycleStep addonmgrv1alpha1.LifecycleStep, addon *addonmgrv1alpha1.Addon, wfl workflows.AddonLifecycle) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
+func (r *AddonReconciler) runWorkflow(lifecycleStep addonmgrv1alpha1.LifecycleStep, addon *addonmgrv1alpha1.Addon) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
 	log := r.Log.WithValues("addon", fmt.Sprintf("%s/%s", addon.Namespace, addon.Name))
 
 	wt, err := addon.GetWorkflowType(lifecycleStep)
@@ -382,7 +385,7 @@ func (r *AddonReconciler) runWorkflow(lifecycleStep addonmgrv1alpha1.LifecycleSt
 		return addonmgrv1alpha1.Failed, err
 	}
 
-	if wt.Template == "" {
+	if wt.Template == "" && wt.TemplateRef == "" {
 		// No workflow was provided, so mark as succeeded
 		return addonmgrv1alpha1.Succeeded, nil
 	}
@@ -391,11 +394,17 @@ func (r *AddonReconciler) runWorkflow(lifecycleStep addonmgrv1alpha1.LifecycleSt
 	if wfIdentifierName == "" {
 		return addonmgrv1alpha1.Failed, fmt.Errorf("could not generate workflow template name")
 	}
-	phase, err := wfl.Install(context.TODO(), wt, wfIdentifierName)
+	phase, wfName, err := r.wfl.Install(context.TODO(), addon, lifecycleStep, wt, wfIdentifierName)
+	if wfName != "" {
+		if addon.Status.Workflows == nil {
+			addon.Status.Workflows = map[addonmgrv1alpha1.LifecycleStep]string{}
+		}
+		addon.Status.Workflows[lifecycleStep] = wfName
+	}
 	if err != nil {
 		return phase, err
 	}
-	r.recorder.Event(addon, "Normal", "Completed", fmt.Sprintf("Completed %s workflow %s/%s.", strings.Title(string(lifecycleStep)), addon.Namespace, wfIdentifierName))
+	r.recorder.Event(addon, "Normal", "Completed", fmt.Sprintf("Completed %s workflow %s/%s.", strings.Title(string(lifecycleStep)), addon.Namespace, wfName))
 	return phase, nil
 }
 
@@ -493,16 +502,16 @@ func (r *AddonReconciler) observeResources(ctx context.Context, a *addonmgrv1alp
 }
 
 // Finalize runs finalizer for addon
-func (r *AddonReconciler) Finalize(ctx context.Context, addon *addonmgrv1alpha1.Addon, wfl workflows.AddonLifecycle, finalizerName string) error {
+func (r *AddonReconciler) Finalize(ctx context.Context, addon *addonmgrv1alpha1.Addon, finalizerName string) error {
 	// Has Delete workflow defined, let's run it.
 	var removeFinalizer = true
 
-	if addon.Spec.Lifecycle.Delete.Template != "" {
+	if addon.Spec.Lifecycle.Delete.Template != "" || addon.Spec.Lifecycle.Delete.TemplateRef != "" {
 
 		removeFinalizer = false
 
 		// Run delete workflow
-		phase, err := r.runWorkflow(addonmgrv1alpha1.Delete, addon, wfl)
+		phase, err := r.runWorkflow(addonmgrv1alpha1.Delete, addon)
 		if err != nil {
 			return err
 		}
@@ -1,6 +1,6 @@
 module github.com/keikoproj/addon-manager
 
-go 1.12
+go 1.13
 
 require (
 	github.com/Masterminds/semver v1.4.2
@@ -13,6 +13,7 @@ require (
 	github.com/onsi/ginkgo v1.8.0
 	github.com/onsi/gomega v1.5.0
 	github.com/pkg/errors v0.8.1
+	github.com/prometheus/client_golang v0.9.0
 	github.com/sirupsen/logrus v1.4.2
 	github.com/spf13/cobra v0.0.5
 	golang.org/x/net v0.0.0-20190501004415-9ce7a6920f09
@@ -75,8 +75,7 @@ func (av *addonValidator) Validate() (bool, error) {
 	}
 
 	// Validate dependencies are resolvable, no diamond dependency cycles.
-	var visited = make(map[string]*Version)
-	err = av.resolveDependencies(version, visited, 0)
+	_, err = ResolveDependencies(av.cache, version)
 	if err != nil {
 		return false, err
 	}
@@ -152,7 +151,7 @@ func (av *addonValidator) validateWorkflow() error {
 		for _, wfParam := range wfParameters {
 			wfParamName := wfParam.(map[string]interface{})["name"].(string)
 			if _, in := addonParams[wfParamName]; in {
-				return fmt.Errorf("invalid workflow, parameter named %q found in addon params and in workflow %s", wfParamName, av.addon.GetFormattedWorkflowName(&wt, key))
+				return fmt.Errorf("invalid workflow, parameter named %q found in addon params and in workflow %s", wfParamName, av.addon.GetFormattedWorkflowName(addonmgrv1alpha1.LifecycleStep(key)))
 			}
 		}
 	}
@@ -169,80 +168,9 @@ func (av *addonValidator) validateAddonNameLength() error {
 
 func (av *addonValidator) validateDependencies() error {
 	// Check addon cache to see that addon pkgName:pkgVersion was installed
-	for pkgName, pkgVersion := range av.addon.Spec.PkgDeps {
-		pkgName = strings.TrimSpace(pkgName)
-		pkgVersion = strings.TrimSpace(pkgVersion)
-
-		if pkgVersion == "*" {
-			// Ignore version
-			versions := av.cache.GetVersions(pkgName)
-			if versions == nil {
-				return fmt.Errorf("required dependency %s is not installed", pkgName)
-			}
-
-			// Look for any successfully installed version
-			var versionFound = false
-			for _, v := range versions {
-				if v.PkgPhase == addonmgrv1alpha1.Succeeded {
-					versionFound = true
-					break
-				}
-			}
-
-			if !versionFound {
-				return fmt.Errorf("required dependency %s has no valid versions installed", pkgName)
-			}
-		} else {
-			// Check for specific version
-			v := av.cache.GetVersion(pkgName, pkgVersion)
-			if v == nil || v.PkgPhase != addonmgrv1alpha1.Succeeded {
-				return fmt.Errorf("required dependency %s:%s is not installed", pkgName, pkgVersion)
-			}
-		}
+	if missing := UnsatisfiedDependencies(av.cache, av.addon.Spec.PkgDeps); len(missing) > 0 {
+		return fmt.Errorf("required dependencies %s are not installed", strings.Join(missing, ", "))
 	}
 
 	return nil
 }
-
-func (av *addonValidator) resolveDependencies(n *Version, visited map[string]*Version, depth int) error {
-	if depth >= 256 {
-		panic("Recursive max depth of 256 seen, this is bad!")
-	}
-
-	// Add to visited
-	name := n.PkgName + ":" + n.PkgVersion
-	visited[name] = n
-
-	for pkgName, pkgVersion := range n.PkgDeps {
-		pkgName = strings.TrimSpace(pkgName)
-		pkgVersion = strings.TrimSpace(pkgVersion)
-
-		if pkgName == n.PkgName {
-			return fmt.Errorf("invalid package dependency, addon cannot depend on it's own package name %s:%s", pkgName, pkgVersion)
-		}
-
-		v := av.cache.GetVersion(pkgName, pkgVersion)
-		if v == nil {
-			// Unresolvable dependency
-			return fmt.Errorf("unable to resolve required dependency %s:%s", pkgName, pkgVersion)
-		}
-
-		// Validate it resolves without cyclic dependency
-		dname := v.PkgName + ":" + v.PkgVersion
-		if _, ok := visited[dname]; ok {
-			return fmt.Errorf("circular dependency was found in %s:%s", v.PkgName, v.PkgVersion)
-		}
-
-		// Recursive - make sure we catch all cases.
-		err := av.resolveDependencies(v, visited, depth+1)
-		if err != nil {
-			return err
-		}
-
-	}
-
-	// Remove visited nodes after
-	delete(visited, name)
-
-	return nil
-}
@@ -591,12 +591,12 @@ func Test_resolveDependencies(t *testing.T) {
 		dynClient: dynClient,
 	}
 
-	var visited = make(map[string]*Version)
-
-	g.Expect(av.resolveDependencies(&Version{
+	deps, err := ResolveDependencies(cached, &Version{
 		PackageSpec: av.addon.GetPackageSpec(),
 		PkgPhase:    addonmgrv1alpha1.Pending,
-	}, visited, 0)).Should(gomega.BeNil(), "Should validate")
+	})
+	g.Expect(err).Should(gomega.BeNil(), "Should validate")
+	g.Expect(deps).Should(gomega.Equal([]string{"core/A", "core/B", "core/C"}))
 }
 
 func Test_resolveDependencies_Fail(t *testing.T) {
@@ -662,10 +662,10 @@ func Test_resolveDependencies_Fail(t *testing.T) {
 		dynClient: dynClient,
 	}
 
-	var visited = make(map[string]*Version)
-
-	g.Expect(av.resolveDependencies(&Version{
+	_, err := ResolveDependencies(cached, &Version{
 		PackageSpec: av.addon.GetPackageSpec(),
 		PkgPhase:    addonmgrv1alpha1.Pending,
-	}, visited, 0)).ShouldNot(gomega.Succeed(), "Should not validate")
+	})
+	g.Expect(err).Should(gomega.BeAssignableToTypeOf(&ErrDependencyCycle{}), "Should not validate")
+	g.Expect(err.(*ErrDependencyCycle).Cycle).Should(gomega.Equal([]string{"core/A", "core/C", "core/A"}))
 }
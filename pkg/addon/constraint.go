@@ -0,0 +1,104 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+var (
+	// plainVersionRegexp matches versions without constraint operators, these only match exactly when not semver
+	plainVersionRegexp = regexp.MustCompile(`^[0-9A-Za-z._+-]+$`)
+	// operatorSpaceRegexp matches constraint operators followed by spaces, e.g. ">= 1.2"
+	operatorSpaceRegexp = regexp.MustCompile(`([<>=!~^]+)\s+`)
+)
+
+// SatisfiesConstraint checks if the installed version satisfies the dependency constraint. The constraint can be
+// "*" for any version, an exact version or a semver range like "~1.4", "^1.2" or ">=1.2.0 <2.0.0".
+func SatisfiesConstraint(installedVersion, constraint string) (bool, error) {
+	installedVersion = strings.TrimSpace(installedVersion)
+	constraint = strings.TrimSpace(constraint)
+
+	if constraint == "*" || constraint == installedVersion {
+		return true, nil
+	}
+
+	ct, err := parseConstraint(constraint)
+	if err != nil {
+		return false, err
+	}
+	if ct == nil {
+		// Plain versions that aren't semver only match exactly
+		return false, nil
+	}
+
+	v, err := semver.NewVersion(installedVersion)
+	if err != nil {
+		return false, fmt.Errorf("invalid installed version %q. %v", installedVersion, err)
+	}
+
+	return ct.Check(v), nil
+}
+
+// ValidateConstraint makes sure the dependency constraint can be matched against installed versions
+func ValidateConstraint(constraint string) error {
+	constraint = strings.TrimSpace(constraint)
+	if constraint == "*" {
+		return nil
+	}
+
+	_, err := parseConstraint(constraint)
+	return err
+}
+
+// ValidatePkgVersion makes sure the package version is semver so dependency constraints can be matched against it,
+// an optional "v" prefix is accepted. An unset version is left to the CRD validation.
+func ValidatePkgVersion(version string) error {
+	if version == "" {
+		return nil
+	}
+
+	if _, err := semver.NewVersion(strings.TrimSpace(version)); err != nil {
+		return fmt.Errorf("invalid pkgVersion %q, must be a semantic version. %v", version, err)
+	}
+
+	return nil
+}
+
+// parseConstraint parses a semver constraint, space separated ranges are accepted as well as comma separated ones.
+// A nil constraint is returned for plain versions that are not semver.
+func parseConstraint(constraint string) (*semver.Constraints, error) {
+	var groups []string
+	for _, group := range strings.Split(constraint, "||") {
+		group = operatorSpaceRegexp.ReplaceAllString(strings.TrimSpace(group), "$1")
+		groups = append(groups, strings.Join(strings.FieldsFunc(group, func(r rune) bool {
+			return r == ' ' || r == ','
+		}), ","))
+	}
+
+	ct, err := semver.NewConstraint(strings.Join(groups, "||"))
+	if err != nil {
+		if plainVersionRegexp.MatchString(constraint) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("invalid version constraint %q. %v", constraint, err)
+	}
+
+	return ct, nil
+}
@@ -0,0 +1,68 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestSatisfiesConstraint(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tests := []struct {
+		installed  string
+		constraint string
+		satisfied  bool
+		wantErr    bool
+	}{
+		{"v0.1.0", "*", true, false},
+		{"v1.0.0", "v1.0.0", true, false},
+		{"v1.0.1", "v1.0.0", false, false},
+		{"latest", "latest", true, false},
+		{"latest", "stable", false, false},
+		{"1.4.3", "^1.2", true, false},
+		{"2.0.0", "^1.2", false, false},
+		{"1.4.9", "~1.4", true, false},
+		{"1.5.0", "~1.4", false, false},
+		{"1.9.0", ">=1.2.0 <2.0.0", true, false},
+		{"2.0.0", ">=1.2.0 <2.0.0", false, false},
+		{"1.3.0", ">= 1.2, < 2", true, false},
+		{"3.1.0", "<2.0.0 || >=3.0.0", true, false},
+		{"1.0.0", ">=1.2.0 <<2", false, true},
+		{"1.0.0", "~>>1", false, true},
+		{"latest", ">=1.2.0", false, true},
+	}
+
+	for _, tc := range tests {
+		satisfied, err := SatisfiesConstraint(tc.installed, tc.constraint)
+		if tc.wantErr {
+			g.Expect(err).To(HaveOccurred(), tc.installed+" "+tc.constraint)
+		} else {
+			g.Expect(err).To(Not(HaveOccurred()), tc.installed+" "+tc.constraint)
+		}
+		g.Expect(satisfied).To(Equal(tc.satisfied), tc.installed+" "+tc.constraint)
+	}
+}
+
+func TestValidatePkgVersion(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(ValidatePkgVersion("1.0.0")).To(Succeed())
+	g.Expect(ValidatePkgVersion("v2.3.1")).To(Succeed())
+	g.Expect(ValidatePkgVersion("1.2.0-rc.1+build.5")).To(Succeed())
+	g.Expect(ValidatePkgVersion("latest")).To(MatchError(ContainSubstring(`invalid pkgVersion "latest"`)))
+}
@@ -0,0 +1,178 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+// ErrDependenciesUnsatisfied is returned when the package dependencies can never be installed
+var ErrDependenciesUnsatisfied = errors.New("package dependencies can't be satisfied")
+
+// ErrDependencyCycle is returned when package dependencies depend on each other
+type ErrDependencyCycle struct {
+	// Cycle lists the package names in dependency order, starting and ending with the same package
+	Cycle []string
+}
+
+func (e *ErrDependencyCycle) Error() string {
+	return fmt.Sprintf("dependency cycle found %s", strings.Join(e.Cycle, " -> "))
+}
+
+// Is lets errors.Is match a dependency cycle as ErrDependenciesUnsatisfied
+func (e *ErrDependencyCycle) Is(target error) bool {
+	return target == ErrDependenciesUnsatisfied
+}
+
+// dependencyGraph maps package names to the package names they depend on
+type dependencyGraph map[string][]string
+
+func (g dependencyGraph) add(pkgName string, pkgDeps map[string]string) {
+	deps := g[pkgName]
+	for dep := range pkgDeps {
+		deps = append(deps, strings.TrimSpace(dep))
+	}
+	sort.Strings(deps)
+	g[pkgName] = deps
+}
+
+// walk calls visit for the package and every package it depends on, dependencies are visited before the packages
+// that depend on them and packages already visited are skipped. An ErrDependencyCycle is returned if a package is
+// reached again through its own dependencies.
+func (g dependencyGraph) walk(pkgName string, visited map[string]bool, visit func(pkgName string)) error {
+	var path []string
+	var walk func(pkgName string) error
+	walk = func(pkgName string) error {
+		for i, p := range path {
+			if p == pkgName {
+				cycle := append([]string{}, path[i:]...)
+				return &ErrDependencyCycle{Cycle: append(cycle, pkgName)}
+			}
+		}
+		if visited[pkgName] {
+			return nil
+		}
+
+		path = append(path, pkgName)
+		for _, dep := range g[pkgName] {
+			if err := walk(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+
+		visited[pkgName] = true
+		visit(pkgName)
+
+		return nil
+	}
+
+	return walk(pkgName)
+}
+
+// ResolveDependencies walks the package dependencies of the version through the cached versions and returns the
+// sorted package names it depends on directly or transitively. An ErrDependencyCycle is returned if a package
+// depends on itself.
+func ResolveDependencies(cache VersionCacheClient, v *Version) ([]string, error) {
+	graph := make(dependencyGraph)
+	for _, versions := range cache.GetAllVersions() {
+		for _, cached := range versions {
+			// The version may be cached from an earlier spec of the same addon
+			if v.Name != "" && cached.Name == v.Name && cached.Namespace == v.Namespace {
+				continue
+			}
+			graph.add(cached.PkgName, cached.PkgDeps)
+		}
+	}
+	graph.add(v.PkgName, v.PkgDeps)
+
+	var deps []string
+	err := graph.walk(v.PkgName, make(map[string]bool), func(pkgName string) {
+		if pkgName != v.PkgName {
+			deps = append(deps, pkgName)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(deps)
+
+	return deps, nil
+}
+
+// UnsatisfiedDependencies returns the package dependencies without a succeeded cached version that satisfies their
+// version constraint, sorted as pkgName:pkgVersion
+func UnsatisfiedDependencies(cache VersionCacheClient, pkgDeps map[string]string) []string {
+	var missing []string
+	for pkgName, pkgVersion := range pkgDeps {
+		pkgName = strings.TrimSpace(pkgName)
+		pkgVersion = strings.TrimSpace(pkgVersion)
+
+		var found = false
+		for _, v := range cache.GetVersions(pkgName) {
+			if v.PkgPhase != addonmgrv1alpha1.Succeeded {
+				continue
+			}
+			// Installed versions that can't be compared never satisfy a range
+			if ok, _ := SatisfiesConstraint(v.PkgVersion, pkgVersion); ok {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			missing = append(missing, pkgName+":"+pkgVersion)
+		}
+	}
+	sort.Strings(missing)
+
+	return missing
+}
+
+// ResolveInstallOrder returns the package names of the addons ordered so that every package comes after its
+// dependencies. Dependencies that are not one of the addons are expected to be installed already and are left out.
+// An ErrDependencyCycle is returned if packages depend on each other.
+func ResolveInstallOrder(addons []*addonmgrv1alpha1.Addon) ([]string, error) {
+	graph := make(dependencyGraph)
+	for _, a := range addons {
+		graph.add(a.Spec.PkgName, a.Spec.PkgDeps)
+	}
+
+	pkgNames := make([]string, 0, len(graph))
+	for pkgName := range graph {
+		pkgNames = append(pkgNames, pkgName)
+	}
+	sort.Strings(pkgNames)
+
+	var order []string
+	visited := make(map[string]bool)
+	for _, pkgName := range pkgNames {
+		err := graph.walk(pkgName, visited, func(pkgName string) {
+			if _, ok := graph[pkgName]; ok {
+				order = append(order, pkgName)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
@@ -0,0 +1,154 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package addon
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func newDependentAddon(pkgName string, deps ...string) *v1alpha1.Addon {
+	pkgDeps := make(map[string]string)
+	for _, dep := range deps {
+		pkgDeps[dep] = "*"
+	}
+	return &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: pkgName, Namespace: "default"},
+		Spec: v1alpha1.AddonSpec{
+			PackageSpec: v1alpha1.PackageSpec{PkgName: pkgName, PkgVersion: "1.0.0", PkgType: v1alpha1.HelmPkg, PkgDeps: pkgDeps},
+		},
+	}
+}
+
+func TestResolveInstallOrder(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tests := []struct {
+		name     string
+		addons   []*v1alpha1.Addon
+		expected []string
+	}{
+		{
+			name:     "empty",
+			expected: nil,
+		},
+		{
+			name: "chain",
+			addons: []*v1alpha1.Addon{
+				newDependentAddon("a", "b"),
+				newDependentAddon("b", "c"),
+				newDependentAddon("c"),
+			},
+			expected: []string{"c", "b", "a"},
+		},
+		{
+			name: "diamond",
+			addons: []*v1alpha1.Addon{
+				newDependentAddon("app", "left", "right"),
+				newDependentAddon("left", "base"),
+				newDependentAddon("right", "base"),
+				newDependentAddon("base"),
+			},
+			expected: []string{"base", "left", "right", "app"},
+		},
+		{
+			name: "installed-dependency",
+			addons: []*v1alpha1.Addon{
+				newDependentAddon("a", "core/installed"),
+				newDependentAddon("b", "a"),
+			},
+			expected: []string{"a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		order, err := ResolveInstallOrder(tt.addons)
+		g.Expect(err).To(Not(HaveOccurred()), tt.name)
+		g.Expect(order).To(Equal(tt.expected), tt.name)
+	}
+}
+
+func TestResolveInstallOrder_Cycle(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	_, err := ResolveInstallOrder([]*v1alpha1.Addon{
+		newDependentAddon("a", "b"),
+		newDependentAddon("b", "c"),
+		newDependentAddon("c", "a"),
+		newDependentAddon("d"),
+	})
+	g.Expect(err).To(BeAssignableToTypeOf(&ErrDependencyCycle{}))
+	g.Expect(err.(*ErrDependencyCycle).Cycle).To(Equal([]string{"a", "b", "c", "a"}))
+	g.Expect(errors.Is(err, ErrDependenciesUnsatisfied)).To(BeTrue())
+}
+
+func TestUnsatisfiedDependencies(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	cache := NewAddonVersionCacheClient()
+	cache.AddVersion(Version{
+		PackageSpec: v1alpha1.PackageSpec{PkgName: "core/A", PkgVersion: "1.0.0"},
+		PkgPhase:    v1alpha1.Succeeded,
+	})
+	cache.AddVersion(Version{
+		PackageSpec: v1alpha1.PackageSpec{PkgName: "core/B", PkgVersion: "1.0.0"},
+		PkgPhase:    v1alpha1.Failed,
+	})
+
+	tests := []struct {
+		name     string
+		pkgDeps  map[string]string
+		expected []string
+	}{
+		{
+			name:     "none",
+			expected: nil,
+		},
+		{
+			name:     "installed",
+			pkgDeps:  map[string]string{"core/A": "1.0.0"},
+			expected: nil,
+		},
+		{
+			name:     "any-version",
+			pkgDeps:  map[string]string{" core/A ": "*"},
+			expected: nil,
+		},
+		{
+			name:     "other-version",
+			pkgDeps:  map[string]string{"core/A": "2.0.0"},
+			expected: []string{"core/A:2.0.0"},
+		},
+		{
+			name:     "failed",
+			pkgDeps:  map[string]string{"core/B": "*"},
+			expected: []string{"core/B:*"},
+		},
+		{
+			name:     "missing",
+			pkgDeps:  map[string]string{"core/C": "1.0.0", "core/A": "*", "core/B": "1.0.0"},
+			expected: []string{"core/B:1.0.0", "core/C:1.0.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		g.Expect(UnsatisfiedDependencies(cache, tt.pkgDeps)).To(Equal(tt.expected), tt.name)
+	}
+}
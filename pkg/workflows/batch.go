@@ -0,0 +1,98 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/addon"
+)
+
+// BatchInstaller submits the install workflows of several addons, e.g. to bootstrap a cluster
+type BatchInstaller struct {
+	wfl AddonLifecycle
+}
+
+// NewBatchInstaller returns a BatchInstaller, the options configure the workflow lifecycle of every addon
+func NewBatchInstaller(client client.Client, dynClient dynamic.Interface, recorder record.EventRecorder, scheme *runtime.Scheme, opts ...Option) *BatchInstaller {
+	return &BatchInstaller{
+		wfl: NewWorkflowLifecycleWithOptions(client, dynClient, recorder, scheme, opts...),
+	}
+}
+
+// InstallBatch installs the addons in dependency order, wts holds the install workflow type of the addon at the same
+// index. An addon is only submitted once the addons of the batch it depends on have Succeeded, until then it is
+// Pending, so InstallBatch is meant to be called again until every addon has completed. The phases are returned by
+// addon namespace/name, an install error fails the addon and is returned once the rest of the batch was submitted.
+func (b *BatchInstaller) InstallBatch(ctx context.Context, addons []*addonmgrv1alpha1.Addon, wts []*addonmgrv1alpha1.WorkflowType) (map[string]addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
+	if len(addons) != len(wts) {
+		return nil, fmt.Errorf("got %d workflow types for %d addons", len(wts), len(addons))
+	}
+
+	byPkgName := make(map[string]int, len(addons))
+	for i, a := range addons {
+		if _, ok := byPkgName[a.Spec.PkgName]; ok {
+			return nil, fmt.Errorf("package %s is installed by more than one addon", a.Spec.PkgName)
+		}
+		byPkgName[a.Spec.PkgName] = i
+	}
+
+	order, err := addon.ResolveInstallOrder(addons)
+	if err != nil {
+		return nil, err
+	}
+
+	phases := make(map[string]addonmgrv1alpha1.ApplicationAssemblyPhase, len(addons))
+	pkgPhases := make(map[string]addonmgrv1alpha1.ApplicationAssemblyPhase, len(addons))
+	var errs []error
+	for _, pkgName := range order {
+		i := byPkgName[pkgName]
+		a := addons[i]
+
+		phase := addonmgrv1alpha1.Pending
+		if b.dependenciesSucceeded(a, pkgPhases) {
+			phase, _, err = b.wfl.Install(ctx, a, addonmgrv1alpha1.Install, wts[i], a.GetFormattedWorkflowName(addonmgrv1alpha1.Install))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s/%s: %v", a.GetNamespace(), a.GetName(), err))
+			}
+		}
+
+		phases[a.GetNamespace()+"/"+a.GetName()] = phase
+		pkgPhases[pkgName] = phase
+	}
+
+	return phases, utilerrors.NewAggregate(errs)
+}
+
+// dependenciesSucceeded reports whether the addon dependencies that are part of the batch have Succeeded
+func (b *BatchInstaller) dependenciesSucceeded(a *addonmgrv1alpha1.Addon, pkgPhases map[string]addonmgrv1alpha1.ApplicationAssemblyPhase) bool {
+	for pkgName := range a.Spec.PkgDeps {
+		phase, ok := pkgPhases[strings.TrimSpace(pkgName)]
+		if ok && phase != addonmgrv1alpha1.Succeeded {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,112 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	dynfake "k8s.io/client-go/dynamic/fake"
+
+	"github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+func newDependentAddon(pkgName string, deps ...string) *v1alpha1.Addon {
+	pkgDeps := make(map[string]string)
+	for _, dep := range deps {
+		pkgDeps[dep] = "*"
+	}
+	a := newTestAddon(pkgName, pkgName)
+	a.Spec.PkgDeps = pkgDeps
+	return a
+}
+
+func TestBatchInstaller_InstallBatch(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	// a depends on b which depends on c
+	addons := []*v1alpha1.Addon{
+		newDependentAddon("a", "b"),
+		newDependentAddon("b", "c"),
+		newDependentAddon("c"),
+	}
+	wts := make([]*v1alpha1.WorkflowType, len(addons))
+	for i, a := range addons {
+		a.SetUID(types.UID("2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a" + a.GetName()))
+		wts[i] = &v1alpha1.WorkflowType{Template: wfSpecTemplate}
+	}
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	b := NewBatchInstaller(fclient, dyn, rcdr, sch)
+
+	// Only the addon without dependencies is submitted
+	phases, err := b.InstallBatch(context.Background(), addons, wts)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phases).To(Equal(map[string]v1alpha1.ApplicationAssemblyPhase{
+		"default/a": v1alpha1.Pending,
+		"default/b": v1alpha1.Pending,
+		"default/c": v1alpha1.Pending,
+	}))
+
+	workflows := dyn.Resource(common.WorkflowGVR()).Namespace("default")
+	_, err = workflows.Get(addons[2].GetFormattedWorkflowName(v1alpha1.Install), metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	_, err = workflows.Get(addons[1].GetFormattedWorkflowName(v1alpha1.Install), metav1.GetOptions{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+	// The dependent is submitted once its prerequisite succeeded
+	wf, err := workflows.Get(addons[2].GetFormattedWorkflowName(v1alpha1.Install), metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	wf.Object["status"] = map[string]interface{}{"phase": "Succeeded", "startedAt": time.Now().Format(time.RFC3339)}
+	_, err = workflows.Update(wf, metav1.UpdateOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	phases, err = b.InstallBatch(context.Background(), addons, wts)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phases).To(Equal(map[string]v1alpha1.ApplicationAssemblyPhase{
+		"default/a": v1alpha1.Pending,
+		"default/b": v1alpha1.Pending,
+		"default/c": v1alpha1.Succeeded,
+	}))
+
+	_, err = workflows.Get(addons[1].GetFormattedWorkflowName(v1alpha1.Install), metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	_, err = workflows.Get(addons[0].GetFormattedWorkflowName(v1alpha1.Install), metav1.GetOptions{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+	// Addons with the same name in different namespaces are reported apart
+	other := newDependentAddon("a")
+	other.Namespace = "other"
+	other.Spec.PkgName = "other/a"
+	other.SetUID("2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0ax")
+	phases, err = b.InstallBatch(context.Background(), []*v1alpha1.Addon{addons[0], other}, wts[:2])
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phases).To(HaveKeyWithValue("default/a", v1alpha1.Pending))
+	g.Expect(phases).To(HaveKeyWithValue("other/a", v1alpha1.Pending))
+
+	// Mismatched workflow types are rejected
+	_, err = b.InstallBatch(context.Background(), addons, wts[:2])
+	g.Expect(err).To(MatchError("got 2 workflow types for 3 addons"))
+
+	// Cycles are rejected
+	_, err = b.InstallBatch(context.Background(), []*v1alpha1.Addon{newDependentAddon("x", "y"), newDependentAddon("y", "x")}, wts[:2])
+	g.Expect(err).To(BeAssignableToTypeOf(&ErrDependencyCycle{}))
+}
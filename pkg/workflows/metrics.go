@@ -0,0 +1,45 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// workflowSubmissions counts Install calls by the returned phase and the addon package type
+	workflowSubmissions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "addon_workflow_submissions_total",
+			Help: "Total number of addon workflow submissions by phase and package type",
+		},
+		[]string{"phase", "pkgtype"},
+	)
+
+	// workflowSubmitDuration measures how long the apiserver takes to create a workflow
+	workflowSubmitDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "addon_workflow_submit_duration_seconds",
+			Help:    "Latency of creating addon workflows in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+)
+
+func init() {
+	// Register with the controller-runtime registry so metrics are served on the manager metrics endpoint
+	metrics.Registry.MustRegister(workflowSubmissions, workflowSubmitDuration)
+}
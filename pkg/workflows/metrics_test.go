@@ -0,0 +1,53 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dynfake "k8s.io/client-go/dynamic/fake"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/keikoproj/addon-manager/api/v1alpha1"
+)
+
+func TestWorkflowMetrics(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+	a.Spec.PkgType = v1alpha1.CompositePkg
+
+	submissions := workflowSubmissions.WithLabelValues(string(v1alpha1.Pending), string(v1alpha1.CompositePkg))
+	before := testutil.ToFloat64(submissions)
+
+	wfl := NewWorkflowLifecycle(fclient, dynfake.NewSimpleDynamicClient(sch), rcdr, sch)
+
+	_, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(testutil.ToFloat64(submissions)).To(Equal(before + 1))
+
+	// Both metrics are served from the controller-runtime registry
+	families, err := metrics.Registry.Gather()
+	g.Expect(err).To(Not(HaveOccurred()))
+	var names []string
+	for _, family := range families {
+		names = append(names, family.GetName())
+	}
+	g.Expect(names).To(ContainElement("addon_workflow_submissions_total"))
+	g.Expect(names).To(ContainElement("addon_workflow_submit_duration_seconds"))
+}
@@ -0,0 +1,146 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// redactedValue replaces the values of secret params in logs and events
+const redactedValue = "***"
+
+// redactSecretParams makes the recorder and logger of the addon redact the values the params have for the addon secret
+// params. The addon data and package params are redacted when the addon is bound, the params read from a
+// paramsFromConfigMap once they are.
+func (w *addonWorkflows) redactSecretParams(params ...map[string]string) {
+	if redactor := newRedactor(w.addon.Spec.Params.SecretParams, params...); redactor != nil {
+		w.recorder = &redactingRecorder{EventRecorder: w.recorder, redactor: redactor}
+		w.log = &redactingLogger{Logger: w.log, redactor: redactor}
+	}
+}
+
+// newRedactor returns a replacer of the values the params have for the secret param keys, nil if there are none
+func newRedactor(secretParams []string, params ...map[string]string) *strings.Replacer {
+	var values []string
+	for _, key := range secretParams {
+		for _, p := range params {
+			if value := p[key]; value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	// Replace longer values first so a value containing another one is redacted entirely
+	sort.SliceStable(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+	oldnew := make([]string, 0, 2*len(values))
+	for _, value := range values {
+		oldnew = append(oldnew, value, redactedValue)
+	}
+
+	return strings.NewReplacer(oldnew...)
+}
+
+// redactingRecorder redacts the secret param values from event messages
+type redactingRecorder struct {
+	record.EventRecorder
+	redactor *strings.Replacer
+}
+
+func (r *redactingRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	r.EventRecorder.Event(object, eventtype, reason, r.redactor.Replace(message))
+}
+
+func (r *redactingRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.EventRecorder.Event(object, eventtype, reason, r.redactor.Replace(fmt.Sprintf(messageFmt, args...)))
+}
+
+func (r *redactingRecorder) PastEventf(object runtime.Object, timestamp metav1.Time, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.EventRecorder.PastEventf(object, timestamp, eventtype, reason, "%s", r.redactor.Replace(fmt.Sprintf(messageFmt, args...)))
+}
+
+func (r *redactingRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.EventRecorder.AnnotatedEventf(object, annotations, eventtype, reason, "%s", r.redactor.Replace(fmt.Sprintf(messageFmt, args...)))
+}
+
+// redactingLogger redacts the secret param values from log messages and values
+type redactingLogger struct {
+	logr.Logger
+	redactor *strings.Replacer
+}
+
+func (l *redactingLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.Logger.Info(l.redactor.Replace(msg), redactValues(l.redactor, keysAndValues)...)
+}
+
+func (l *redactingLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	if err != nil {
+		err = errors.New(l.redactor.Replace(err.Error()))
+	}
+	l.Logger.Error(err, l.redactor.Replace(msg), redactValues(l.redactor, keysAndValues)...)
+}
+
+func (l *redactingLogger) V(level int) logr.InfoLogger {
+	return &redactingInfoLogger{InfoLogger: l.Logger.V(level), redactor: l.redactor}
+}
+
+func (l *redactingLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	return &redactingLogger{Logger: l.Logger.WithValues(redactValues(l.redactor, keysAndValues)...), redactor: l.redactor}
+}
+
+func (l *redactingLogger) WithName(name string) logr.Logger {
+	return &redactingLogger{Logger: l.Logger.WithName(name), redactor: l.redactor}
+}
+
+// redactingInfoLogger redacts the secret param values from verbose log messages and values
+type redactingInfoLogger struct {
+	logr.InfoLogger
+	redactor *strings.Replacer
+}
+
+func (l *redactingInfoLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.InfoLogger.Info(l.redactor.Replace(msg), redactValues(l.redactor, keysAndValues)...)
+}
+
+// redactValues redacts the string, error and stringer log values
+func redactValues(redactor *strings.Replacer, keysAndValues []interface{}) []interface{} {
+	redacted := make([]interface{}, len(keysAndValues))
+	for i, v := range keysAndValues {
+		switch v := v.(type) {
+		case string:
+			redacted[i] = redactor.Replace(v)
+		case error:
+			redacted[i] = redactor.Replace(v.Error())
+		case fmt.Stringer:
+			redacted[i] = redactor.Replace(v.String())
+		default:
+			redacted[i] = v
+		}
+	}
+
+	return redacted
+}
@@ -0,0 +1,119 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workflows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	runtimefake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/common"
+)
+
+func TestWorkflowLifecycle_SecretParams(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+	a.Spec.Params = v1alpha1.AddonParams{
+		Data:         map[string]v1alpha1.FlexString{"token": "s3cr3t-token", "region": "us-west-2"},
+		SecretParams: []string{"token"},
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	var entries []logEntry
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	// The API server echoes invalid values in its errors
+	dyn.PrependReactor("create", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New(`spec.arguments.parameters[0].value: Invalid value: "s3cr3t-token" in us-west-2`)
+	})
+	wfl := NewWorkflowLifecycleWithOptions(fclient, dyn, recorder, sch, WithLogger(recordingLogger{entries: &entries}))
+
+	_, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(HaveOccurred())
+
+	event := <-recorder.Events
+	g.Expect(event).To(HavePrefix("Warning WorkflowSubmitFailed"))
+	g.Expect(event).To(Not(ContainSubstring("s3cr3t-token")))
+	g.Expect(event).To(ContainSubstring(`Invalid value: "***" in us-west-2`))
+
+	wfl.(*workflowLifecycle).bind(a).log.Error(errors.New("bad token s3cr3t-token"), "logged s3cr3t-token", "token", "s3cr3t-token")
+	g.Expect(entries).To(Not(BeEmpty()))
+	for _, entry := range entries {
+		g.Expect(entry.msg).To(Not(ContainSubstring("s3cr3t-token")))
+		g.Expect(fmt.Sprint(entry.values...)).To(Not(ContainSubstring("s3cr3t-token")))
+	}
+
+	// The workflow still receives the value
+	dyn = dynfake.NewSimpleDynamicClient(sch)
+	wfl = NewWorkflowLifecycle(fclient, dyn, recorder, sch)
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(fmt.Sprint(wf.Object["spec"])).To(ContainSubstring("s3cr3t-token"))
+}
+
+func TestWorkflowLifecycle_SecretParams_Sources(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+	a.Spec.PkgParams = map[string]string{"apiKey": "pkg-s3cr3t"}
+	a.Spec.Params = v1alpha1.AddonParams{
+		SecretParams: []string{"apiKey", "password"},
+	}
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo-params", Namespace: "default"},
+		Data:       map[string]string{"password": "cm-s3cr3t"},
+	}
+	recorder := record.NewFakeRecorder(10)
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	dyn.PrependReactor("create", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New(`Invalid values: "pkg-s3cr3t", "cm-s3cr3t"`)
+	})
+	wfl := NewWorkflowLifecycle(runtimefake.NewFakeClientWithScheme(sch, cm), dyn, recorder, sch)
+
+	// Secret package params and params of the config map are redacted like the addon data
+	wt := &v1alpha1.WorkflowType{Template: wfSpecTemplate, ParamsFromConfigMap: &v1alpha1.ConfigMapRef{Name: "foo-params"}}
+	_, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(HaveOccurred())
+
+	event := <-recorder.Events
+	g.Expect(event).To(HavePrefix("Warning WorkflowSubmitFailed"))
+	g.Expect(event).To(ContainSubstring(`Invalid values: "***", "***"`))
+}
+
+func TestNewRedactor(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(newRedactor(nil)).To(BeNil())
+	g.Expect(newRedactor([]string{"key"}, map[string]string{"other": "abc"})).To(BeNil())
+
+	secretParams := []string{"key", "longKey", "pkgKey", "empty", "missing"}
+	data := map[string]string{"key": "abc", "longKey": "abc123", "empty": ""}
+	pkgParams := map[string]string{"pkgKey": "xyz"}
+	g.Expect(newRedactor(secretParams, data, pkgParams).Replace("abc123 and abc or xyz")).To(Equal("*** and *** or ***"))
+}
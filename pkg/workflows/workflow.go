@@ -16,275 +16,3465 @@ package workflows
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/adler32"
+	"math"
+	"path"
 	"reflect"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	"github.com/ghodss/yaml"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/diff"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
 
 	addonmgrv1alpha1 "github.com/keikoproj/addon-manager/api/v1alpha1"
+	"github.com/keikoproj/addon-manager/pkg/addon"
 	"github.com/keikoproj/addon-manager/pkg/common"
 )
 
+const (
+	// WorkflowAddonLabel is the label identifying the addon that submitted the workflow
+	WorkflowAddonLabel = "addon.keikoproj.io/addon"
+	// WorkflowVersionLabel is the label recording the addon package version
+	WorkflowVersionLabel = "addon.keikoproj.io/version"
+	// WorkflowStepLabel is the label recording the lifecycle step the workflow was submitted for
+	WorkflowStepLabel = "addon.keikoproj.io/lifecycle-step"
+	// WorkflowSourceAnnotation is the annotation recording the namespace/name of the addon
+	WorkflowSourceAnnotation = "addon.keikoproj.io/source"
+	// PropagateLabelsAnnotation is the addon annotation listing the comma separated keys of the addon labels copied
+	// onto its workflows
+	PropagateLabelsAnnotation = "addon.keikoproj.io/propagate-labels"
+	// WorkflowChecksumAnnotation is the annotation recording the checksum of the submitted workflow
+	WorkflowChecksumAnnotation = "addon.keikoproj.io/checksum"
+	// WorkflowIdempotencyKeyAnnotation is the annotation identifying workflows submitted for the same addon, spec,
+	// lifecycle step and workflow type, so controller replicas adopt each other's workflows instead of submitting
+	// duplicates
+	WorkflowIdempotencyKeyAnnotation = "addon.keikoproj.io/idempotency-key"
+)
+
+// defaultPollInterval is how often a workflow is first checked while waiting on it
+const defaultPollInterval = 5 * time.Second
+
+// maxPollInterval caps the backoff between workflow checks while waiting on a workflow
+const maxPollInterval = time.Minute
+
+// discoveryTTL is how long discovered API resources are reused before the cluster is asked again, a CRD installed
+// for an unmet precondition is noticed within it
+const discoveryTTL = time.Minute
+
+// Actions returned by PlanInstall
+const (
+	// InstallActionCreate is returned when Install would submit a new workflow, a workflow with the name that was
+	// submitted with another checksum is replaced
+	InstallActionCreate = "create"
+	// InstallActionAdopt is returned when Install would report on a matching workflow that was already submitted
+	InstallActionAdopt = "adopt"
+	// InstallActionSkip is returned when a workflow with the name was submitted without a checksum, Install leaves it
+	// as is
+	InstallActionSkip = "skip"
+	// InstallActionWait is returned when Install would wait on dependencies or preconditions without submitting
+	InstallActionWait = "wait"
+	// InstallActionApply is returned when a workflow with the name was submitted with another or without a checksum
+	// and server-side apply is enabled, Install patches it into place
+	InstallActionApply = "apply"
+)
+
+// namePrefixRegexp matches a DNS-1123 label, the name prefix becomes part of the workflow name
+var namePrefixRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// maxNamePrefixLength leaves room in the workflow name for the addon name, lifecycle step and checksum
+const maxNamePrefixLength = 10
+
+// invalidNameCharsRegexp matches runs of characters that are not allowed in a DNS-1123 label
+var invalidNameCharsRegexp = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// maxGenerateNameLength keeps generated workflow names, including the 5 random characters added by the API server,
+// within the 63 character label limit
+const maxGenerateNameLength = 58
+
+// secretsMountPath is the directory addon secrets are mounted under in the workflow templates
+const secretsMountPath = "/etc/addon/secrets"
+
+// Names of the templates added to gate a workflow without suspend steps
+const (
+	suspendGateTemplate       = "addon-suspend-gate"
+	suspendEntrypointTemplate = "addon-suspend-entrypoint"
+)
+
+// paramNameRegexp matches the parameter names accepted by argo
+var paramNameRegexp = regexp.MustCompile(`^[-a-zA-Z0-9_]+$`)
+
+// defaultActiveDeadlineSeconds fails workflows that are still running after 1 hour
+const defaultActiveDeadlineSeconds int64 = 3600
+
+// defaultFieldManager identifies the addon manager as the manager of the workflow fields it creates and patches
+const defaultFieldManager = "addon-manager"
+
+// requeueInterval is how long to wait before reconciling an addon again while its workflows are in progress
+const requeueInterval = 10 * time.Second
+
+// defaultTTLSecondsAfterCompletion cleans up finished workflows after 3 days
+const defaultTTLSecondsAfterCompletion int32 = 259200
+
+// Errors returned by the workflow lifecycle are wrapped, use errors.Is to check for them
+var (
+	// ErrInvalidTemplate is returned when the workflow template can't be parsed or is not a workflow argo can run
+	ErrInvalidTemplate = errors.New("invalid workflow template")
+	// ErrWorkflowCompleted is returned when an operation requires a workflow that has not yet finished
+	ErrWorkflowCompleted = errors.New("workflow has already completed")
+	// ErrWorkflowNotFound is returned when the workflow no longer exists
+	ErrWorkflowNotFound = errors.New("workflow not found")
+	// ErrDependenciesUnsatisfied is returned when the package dependencies can never be installed
+	ErrDependenciesUnsatisfied = addon.ErrDependenciesUnsatisfied
+)
+
+// ErrDependencyCycle is returned when package dependencies depend on each other
+type ErrDependencyCycle = addon.ErrDependencyCycle
+
+// WorkflowInfo summarizes a workflow submitted for an addon
+type WorkflowInfo struct {
+	Name      string
+	Phase     addonmgrv1alpha1.ApplicationAssemblyPhase
+	Type      addonmgrv1alpha1.LifecycleStep
+	CreatedAt metav1.Time
+}
+
+// DependencyStatus reports whether a package dependency of the addon is installed
+type DependencyStatus struct {
+	PkgName string
+	// Constraint is the version requested in addon.spec.pkgDeps
+	Constraint string
+	Satisfied  bool
+	// InstalledVersion is the version of the installed package, empty if the package is not installed
+	InstalledVersion string
+}
+
 // AddonLifecycle represents the following workflows
 type AddonLifecycle interface {
-	Install(context.Context, *addonmgrv1alpha1.WorkflowType, string) (addonmgrv1alpha1.ApplicationAssemblyPhase, error)
-	Delete(string) error
+	Install(context.Context, *addonmgrv1alpha1.Addon, addonmgrv1alpha1.LifecycleStep, *addonmgrv1alpha1.WorkflowType, string) (addonmgrv1alpha1.ApplicationAssemblyPhase, string, error)
+	Delete(context.Context, *addonmgrv1alpha1.Addon, string) error
+	DeleteStrict(context.Context, *addonmgrv1alpha1.Addon, string) error
+	ForceDelete(context.Context, *addonmgrv1alpha1.Addon, string) error
+	DeleteByAddon(context.Context, *addonmgrv1alpha1.Addon) error
+	Suspend(context.Context, *addonmgrv1alpha1.Addon, string) error
+	Resume(context.Context, *addonmgrv1alpha1.Addon, string) error
+	Terminate(context.Context, *addonmgrv1alpha1.Addon, string) error
+	AddWorkflowLabels(context.Context, *addonmgrv1alpha1.Addon, string, map[string]string) error
+	IsWorkflowExpired(context.Context, *addonmgrv1alpha1.Addon, string, time.Duration) (bool, error)
+	RunningDuration(context.Context, *addonmgrv1alpha1.Addon, string) (time.Duration, error)
+	GetStatus(context.Context, *addonmgrv1alpha1.Addon, string) (addonmgrv1alpha1.ApplicationAssemblyPhase, error)
+	IsSucceeded(context.Context, *addonmgrv1alpha1.Addon, string) (bool, error)
+	GetWorkflow(context.Context, *addonmgrv1alpha1.Addon, string) (*unstructured.Unstructured, error)
+	GetOutputs(context.Context, *addonmgrv1alpha1.Addon, string) (map[string]string, error)
+	GetFailedNodeMessages(context.Context, *addonmgrv1alpha1.Addon, string) (map[string]string, error)
+	Progress(context.Context, *addonmgrv1alpha1.Addon, string) (int, int, error)
+	Retry(context.Context, *addonmgrv1alpha1.Addon, string) (addonmgrv1alpha1.ApplicationAssemblyPhase, error)
+	CheckDependencies(context.Context, *addonmgrv1alpha1.Addon) (bool, []string, error)
+	DependencyReport(context.Context, *addonmgrv1alpha1.Addon) ([]DependencyStatus, error)
+	TransitiveDependencies(context.Context, *addonmgrv1alpha1.Addon) ([]string, error)
+	DependentsPresent(context.Context, *addonmgrv1alpha1.Addon) (bool, []string, error)
+	ChecksumInstall(context.Context, *addonmgrv1alpha1.Addon, addonmgrv1alpha1.LifecycleStep, *addonmgrv1alpha1.WorkflowType) (string, error)
+	LastWorkflow(*addonmgrv1alpha1.Addon) (string, addonmgrv1alpha1.ApplicationAssemblyPhase)
+	ListWorkflows(context.Context, *addonmgrv1alpha1.Addon) ([]WorkflowInfo, error)
+	ListByLabelSelector(context.Context, *addonmgrv1alpha1.Addon, labels.Selector) ([]WorkflowInfo, error)
+	FindExistingWorkflow(context.Context, string, string, string) (string, addonmgrv1alpha1.ApplicationAssemblyPhase, bool, error)
+	DryRunInstall(context.Context, *addonmgrv1alpha1.Addon, addonmgrv1alpha1.LifecycleStep, *addonmgrv1alpha1.WorkflowType, string) (*unstructured.Unstructured, error)
+	DetectDrift(context.Context, *addonmgrv1alpha1.Addon, addonmgrv1alpha1.LifecycleStep, *addonmgrv1alpha1.WorkflowType, string) (bool, string, error)
+	PlanInstall(context.Context, *addonmgrv1alpha1.Addon, addonmgrv1alpha1.LifecycleStep, *addonmgrv1alpha1.WorkflowType, string) (string, error)
+	WaitForCompletion(context.Context, *addonmgrv1alpha1.Addon, string, time.Duration) (addonmgrv1alpha1.ApplicationAssemblyPhase, error)
+	RunDeleteWorkflow(context.Context, *addonmgrv1alpha1.Addon, *addonmgrv1alpha1.WorkflowType) (addonmgrv1alpha1.ApplicationAssemblyPhase, error)
+	InstallLifecycle(context.Context, *addonmgrv1alpha1.Addon, *addonmgrv1alpha1.WorkflowType, *addonmgrv1alpha1.WorkflowType) (addonmgrv1alpha1.ApplicationAssemblyPhase, error)
+	ResubmitIfChanged(context.Context, *addonmgrv1alpha1.Addon, addonmgrv1alpha1.LifecycleStep, *addonmgrv1alpha1.WorkflowType, string) (addonmgrv1alpha1.ApplicationAssemblyPhase, string, bool, error)
+	EnsureInstalled(context.Context, *addonmgrv1alpha1.Addon, addonmgrv1alpha1.LifecycleStep, *addonmgrv1alpha1.WorkflowType, string) (addonmgrv1alpha1.ApplicationAssemblyPhase, string, error)
+	WorkflowConditions(context.Context, *addonmgrv1alpha1.Addon, string) ([]addonmgrv1alpha1.Condition, error)
+	OnPhaseChange(PhaseChangeFunc)
+	HealthCheck(context.Context) error
 }
 
+// PhaseChangeFunc is called with the addon and workflow name when a change of its phase is observed, from is empty for
+// the first observed phase
+type PhaseChangeFunc func(addon *addonmgrv1alpha1.Addon, name string, from, to addonmgrv1alpha1.ApplicationAssemblyPhase)
+
+// workflowLifecycle holds the clients and options shared by the addons, the addon is passed to every call so reconciles
+// of different addons can share it. It is safe for concurrent use, the last workflows are guarded by lastMutex and the
+// phase hooks by hooksMutex.
 type workflowLifecycle struct {
 	client.Client
 	dynClient dynamic.Interface
-	addon     *addonmgrv1alpha1.Addon
+	discovery discovery.DiscoveryInterface
 	recorder  record.EventRecorder
 	scheme    *runtime.Scheme
+	gvr       schema.GroupVersionResource
+
+	namespace      string
+	defaultTTL     int32
+	activeDeadline int64
+	pollInterval   time.Duration
+	registryMirror string
+	apply          bool
+	fieldManager   string
+	log            logr.Logger
+
+	// lastWorkflows are the most recently submitted workflows by addon
+	lastMutex     sync.RWMutex
+	lastWorkflows map[types.NamespacedName]lastWorkflow
+
+	// servedGroups and apiResources are the discovered API resources by group version, they're shared by the addons
+	// and discovered again after discoveryTTL
+	discoveryMutex sync.Mutex
+	discoveredAt   time.Time
+	servedGroups   map[string]bool
+	apiResources   map[string]*metav1.APIResourceList
+
+	// waiting is what the addons waited on in their last reconcile
+	waitingMutex sync.Mutex
+	waiting      map[waitingKey]string
+
+	hooksMutex sync.RWMutex
+	phaseHooks []PhaseChangeFunc
+}
+
+// waitingKey is an addon and the reason of the event recorded while it waits
+type waitingKey struct {
+	addon  types.NamespacedName
+	reason string
+}
+
+// lastWorkflow is the name and phase of a submitted workflow
+type lastWorkflow struct {
+	name  string
+	phase addonmgrv1alpha1.ApplicationAssemblyPhase
+}
+
+// addonWorkflows runs the lifecycle of a single addon for the duration of a call, it works on a private copy of the
+// addon with a recorder and logger for the addon
+type addonWorkflows struct {
+	*workflowLifecycle
+	addon    *addonmgrv1alpha1.Addon
+	recorder record.EventRecorder
+	log      logr.Logger
+}
+
+// Option configures the workflow lifecycle
+type Option func(*workflowLifecycle)
+
+// WithWorkflowNamespace submits workflows to the namespace instead of the addon namespace, an empty namespace uses the
+// addon namespace
+func WithWorkflowNamespace(namespace string) Option {
+	return func(w *workflowLifecycle) {
+		w.namespace = namespace
+	}
+}
+
+// WithDefaultTTL sets the ttl of finished workflows when neither the workflow type nor the template has one
+func WithDefaultTTL(seconds int32) Option {
+	return func(w *workflowLifecycle) {
+		w.defaultTTL = seconds
+	}
+}
+
+// WithActiveDeadline sets the workflow deadline when neither the workflow type nor the template has one
+func WithActiveDeadline(seconds int64) Option {
+	return func(w *workflowLifecycle) {
+		w.activeDeadline = seconds
+	}
+}
+
+// WithLogger sets the logger, workflow operations are not logged by default
+func WithLogger(log logr.Logger) Option {
+	return func(w *workflowLifecycle) {
+		if log != nil {
+			w.log = log
+		}
+	}
+}
+
+// WithPollInterval sets how often a workflow is first checked while waiting on it
+func WithPollInterval(interval time.Duration) Option {
+	return func(w *workflowLifecycle) {
+		w.pollInterval = interval
+	}
+}
+
+// WithRegistryMirror rewrites the images of the workflow templates to be pulled from the registry mirror host
+func WithRegistryMirror(mirror string) Option {
+	return func(w *workflowLifecycle) {
+		w.registryMirror = strings.TrimSuffix(mirror, "/")
+	}
+}
+
+// WithServerSideApply submits named workflows with a server-side apply patch instead of creating them, so workflows
+// already applied by another controller are adopted. Generated names are still created.
+func WithServerSideApply() Option {
+	return func(w *workflowLifecycle) {
+		w.apply = true
+	}
+}
+
+// WithFieldManager sets the field manager workflows are created and patched with, it identifies the addon manager in
+// audit logs and server-side apply conflicts
+func WithFieldManager(manager string) Option {
+	return func(w *workflowLifecycle) {
+		if manager != "" {
+			w.fieldManager = manager
+		}
+	}
+}
+
+// WithWorkflowGVR sets the group, version and resource of argo workflows for clusters that run another argo API version
+func WithWorkflowGVR(gvr schema.GroupVersionResource) Option {
+	return func(w *workflowLifecycle) {
+		w.gvr = gvr
+	}
+}
+
+// WithDiscoveryClient sets the discovery client used to check the API preconditions of workflow types
+func WithDiscoveryClient(d discovery.DiscoveryInterface) Option {
+	return func(w *workflowLifecycle) {
+		w.discovery = d
+	}
 }
 
 // NewWorkflowLifecycle returns a NewWorkflowLifecycle object
-func NewWorkflowLifecycle(client client.Client, dynClient dynamic.Interface, addon *addonmgrv1alpha1.Addon, recorder record.EventRecorder, scheme *runtime.Scheme) AddonLifecycle {
-	return &workflowLifecycle{
+func NewWorkflowLifecycle(client client.Client, dynClient dynamic.Interface, recorder record.EventRecorder, scheme *runtime.Scheme) AddonLifecycle {
+	return NewWorkflowLifecycleWithOptions(client, dynClient, recorder, scheme)
+}
+
+// NewWorkflowLifecycleWithOptions returns a NewWorkflowLifecycle object configured by the options
+func NewWorkflowLifecycleWithOptions(client client.Client, dynClient dynamic.Interface, recorder record.EventRecorder, scheme *runtime.Scheme, opts ...Option) AddonLifecycle {
+	w := &workflowLifecycle{
 		Client:    client,
 		dynClient: dynClient,
-		addon:     addon,
 		recorder:  recorder,
 		scheme:    scheme,
+		gvr:       common.WorkflowGVR(),
+
+		defaultTTL:     defaultTTLSecondsAfterCompletion,
+		activeDeadline: defaultActiveDeadlineSeconds,
+		pollInterval:   defaultPollInterval,
+		fieldManager:   defaultFieldManager,
+		log:            ctrllog.NullLogger{},
+		lastWorkflows:  make(map[types.NamespacedName]lastWorkflow),
+		waiting:        make(map[waitingKey]string),
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// bind returns the lifecycle of the addon, changes made to the addon afterwards are not seen by it
+func (w *workflowLifecycle) bind(addon *addonmgrv1alpha1.Addon) *addonWorkflows {
+	a := &addonWorkflows{
+		workflowLifecycle: w,
+		addon:             addon.DeepCopy(),
+		recorder:          w.recorder,
+		log:               w.log.WithValues("addon", fmt.Sprintf("%s/%s", addon.GetNamespace(), addon.GetName())),
+	}
+
+	data := make(map[string]string, len(a.addon.Spec.Params.Data))
+	for k, v := range a.addon.Spec.Params.Data {
+		data[k] = string(v)
+	}
+	a.redactSecretParams(data, a.addon.Spec.PkgParams)
+
+	return a
+}
+
+// The AddonLifecycle methods run the lifecycle of the addon passed to the call
+
+func (w *workflowLifecycle) Install(ctx context.Context, addon *addonmgrv1alpha1.Addon, step addonmgrv1alpha1.LifecycleStep, wt *addonmgrv1alpha1.WorkflowType, name string) (addonmgrv1alpha1.ApplicationAssemblyPhase, string, error) {
+	return w.bind(addon).Install(ctx, step, wt, name)
+}
+
+func (w *workflowLifecycle) Delete(ctx context.Context, addon *addonmgrv1alpha1.Addon, name string) error {
+	return w.bind(addon).Delete(ctx, name)
+}
+
+func (w *workflowLifecycle) DeleteStrict(ctx context.Context, addon *addonmgrv1alpha1.Addon, name string) error {
+	return w.bind(addon).DeleteStrict(ctx, name)
+}
+
+func (w *workflowLifecycle) ForceDelete(ctx context.Context, addon *addonmgrv1alpha1.Addon, name string) error {
+	return w.bind(addon).ForceDelete(ctx, name)
+}
+
+func (w *workflowLifecycle) DeleteByAddon(ctx context.Context, addon *addonmgrv1alpha1.Addon) error {
+	return w.bind(addon).DeleteByAddon(ctx)
+}
+
+func (w *workflowLifecycle) Suspend(ctx context.Context, addon *addonmgrv1alpha1.Addon, name string) error {
+	return w.bind(addon).Suspend(ctx, name)
+}
+
+func (w *workflowLifecycle) Resume(ctx context.Context, addon *addonmgrv1alpha1.Addon, name string) error {
+	return w.bind(addon).Resume(ctx, name)
+}
+
+func (w *workflowLifecycle) Terminate(ctx context.Context, addon *addonmgrv1alpha1.Addon, name string) error {
+	return w.bind(addon).Terminate(ctx, name)
+}
+
+func (w *workflowLifecycle) AddWorkflowLabels(ctx context.Context, addon *addonmgrv1alpha1.Addon, name string, labels map[string]string) error {
+	return w.bind(addon).AddWorkflowLabels(ctx, name, labels)
+}
+
+func (w *workflowLifecycle) IsWorkflowExpired(ctx context.Context, addon *addonmgrv1alpha1.Addon, name string, max time.Duration) (bool, error) {
+	return w.bind(addon).IsWorkflowExpired(ctx, name, max)
+}
+
+func (w *workflowLifecycle) RunningDuration(ctx context.Context, addon *addonmgrv1alpha1.Addon, name string) (time.Duration, error) {
+	return w.bind(addon).RunningDuration(ctx, name)
+}
+
+func (w *workflowLifecycle) GetStatus(ctx context.Context, addon *addonmgrv1alpha1.Addon, name string) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
+	return w.bind(addon).GetStatus(ctx, name)
+}
+
+func (w *workflowLifecycle) IsSucceeded(ctx context.Context, addon *addonmgrv1alpha1.Addon, name string) (bool, error) {
+	return w.bind(addon).IsSucceeded(ctx, name)
+}
+
+func (w *workflowLifecycle) GetWorkflow(ctx context.Context, addon *addonmgrv1alpha1.Addon, name string) (*unstructured.Unstructured, error) {
+	return w.bind(addon).GetWorkflow(ctx, name)
+}
+
+func (w *workflowLifecycle) GetOutputs(ctx context.Context, addon *addonmgrv1alpha1.Addon, name string) (map[string]string, error) {
+	return w.bind(addon).GetOutputs(ctx, name)
+}
+
+func (w *workflowLifecycle) GetFailedNodeMessages(ctx context.Context, addon *addonmgrv1alpha1.Addon, name string) (map[string]string, error) {
+	return w.bind(addon).GetFailedNodeMessages(ctx, name)
+}
+
+func (w *workflowLifecycle) Progress(ctx context.Context, addon *addonmgrv1alpha1.Addon, name string) (completed, total int, err error) {
+	return w.bind(addon).Progress(ctx, name)
+}
+
+func (w *workflowLifecycle) Retry(ctx context.Context, addon *addonmgrv1alpha1.Addon, name string) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
+	return w.bind(addon).Retry(ctx, name)
+}
+
+func (w *workflowLifecycle) CheckDependencies(ctx context.Context, addon *addonmgrv1alpha1.Addon) (bool, []string, error) {
+	return w.bind(addon).CheckDependencies(ctx)
+}
+
+func (w *workflowLifecycle) DependencyReport(ctx context.Context, addon *addonmgrv1alpha1.Addon) ([]DependencyStatus, error) {
+	return w.bind(addon).DependencyReport(ctx)
+}
+
+func (w *workflowLifecycle) TransitiveDependencies(ctx context.Context, addon *addonmgrv1alpha1.Addon) ([]string, error) {
+	return w.bind(addon).TransitiveDependencies(ctx)
+}
+
+func (w *workflowLifecycle) DependentsPresent(ctx context.Context, addon *addonmgrv1alpha1.Addon) (bool, []string, error) {
+	return w.bind(addon).DependentsPresent(ctx)
+}
+
+func (w *workflowLifecycle) ChecksumInstall(ctx context.Context, addon *addonmgrv1alpha1.Addon, step addonmgrv1alpha1.LifecycleStep, wt *addonmgrv1alpha1.WorkflowType) (string, error) {
+	return w.bind(addon).ChecksumInstall(ctx, step, wt)
+}
+
+// LastWorkflow returns the name and phase of the last workflow submitted by Install for the addon
+func (w *workflowLifecycle) LastWorkflow(addon *addonmgrv1alpha1.Addon) (string, addonmgrv1alpha1.ApplicationAssemblyPhase) {
+	w.lastMutex.RLock()
+	defer w.lastMutex.RUnlock()
+	last := w.lastWorkflows[types.NamespacedName{Namespace: addon.GetNamespace(), Name: addon.GetName()}]
+	return last.name, last.phase
+}
+
+func (w *workflowLifecycle) ListWorkflows(ctx context.Context, addon *addonmgrv1alpha1.Addon) ([]WorkflowInfo, error) {
+	return w.bind(addon).ListWorkflows(ctx)
+}
+
+func (w *workflowLifecycle) ListByLabelSelector(ctx context.Context, addon *addonmgrv1alpha1.Addon, selector labels.Selector) ([]WorkflowInfo, error) {
+	return w.bind(addon).ListByLabelSelector(ctx, selector)
+}
+
+func (w *workflowLifecycle) DryRunInstall(ctx context.Context, addon *addonmgrv1alpha1.Addon, step addonmgrv1alpha1.LifecycleStep, wt *addonmgrv1alpha1.WorkflowType, name string) (*unstructured.Unstructured, error) {
+	return w.bind(addon).DryRunInstall(ctx, step, wt, name)
+}
+
+func (w *workflowLifecycle) DetectDrift(ctx context.Context, addon *addonmgrv1alpha1.Addon, step addonmgrv1alpha1.LifecycleStep, wt *addonmgrv1alpha1.WorkflowType, name string) (bool, string, error) {
+	return w.bind(addon).DetectDrift(ctx, step, wt, name)
+}
+
+func (w *workflowLifecycle) PlanInstall(ctx context.Context, addon *addonmgrv1alpha1.Addon, step addonmgrv1alpha1.LifecycleStep, wt *addonmgrv1alpha1.WorkflowType, name string) (string, error) {
+	return w.bind(addon).PlanInstall(ctx, step, wt, name)
+}
+
+func (w *workflowLifecycle) WaitForCompletion(ctx context.Context, addon *addonmgrv1alpha1.Addon, name string, pollInterval time.Duration) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
+	return w.bind(addon).WaitForCompletion(ctx, name, pollInterval)
+}
+
+func (w *workflowLifecycle) RunDeleteWorkflow(ctx context.Context, addon *addonmgrv1alpha1.Addon, wt *addonmgrv1alpha1.WorkflowType) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
+	return w.bind(addon).RunDeleteWorkflow(ctx, wt)
+}
+
+func (w *workflowLifecycle) InstallLifecycle(ctx context.Context, addon *addonmgrv1alpha1.Addon, prereq, install *addonmgrv1alpha1.WorkflowType) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
+	return w.bind(addon).InstallLifecycle(ctx, prereq, install)
+}
+
+func (w *workflowLifecycle) ResubmitIfChanged(ctx context.Context, addon *addonmgrv1alpha1.Addon, step addonmgrv1alpha1.LifecycleStep, wt *addonmgrv1alpha1.WorkflowType, existingWfName string) (addonmgrv1alpha1.ApplicationAssemblyPhase, string, bool, error) {
+	return w.bind(addon).ResubmitIfChanged(ctx, step, wt, existingWfName)
+}
+
+func (w *workflowLifecycle) EnsureInstalled(ctx context.Context, addon *addonmgrv1alpha1.Addon, step addonmgrv1alpha1.LifecycleStep, wt *addonmgrv1alpha1.WorkflowType, existingWfName string) (addonmgrv1alpha1.ApplicationAssemblyPhase, string, error) {
+	return w.bind(addon).EnsureInstalled(ctx, step, wt, existingWfName)
+}
+
+func (w *workflowLifecycle) WorkflowConditions(ctx context.Context, addon *addonmgrv1alpha1.Addon, name string) ([]addonmgrv1alpha1.Condition, error) {
+	return w.bind(addon).WorkflowConditions(ctx, name)
+}
+
+// workflowNamespace is the namespace workflows are submitted to
+func (w *addonWorkflows) workflowNamespace() string {
+	if w.namespace != "" {
+		return w.namespace
+	}
+	return w.addon.GetNamespace()
+}
+
+// Install submits the workflow and returns its phase along with the workflow name. When name is empty the
+// workflow is submitted using generateName and the server assigned name is returned. A workflow identical to one
+// submitted before, going by the checksum annotation, isn't submitted again.
+func (w *addonWorkflows) Install(ctx context.Context, step addonmgrv1alpha1.LifecycleStep, wt *addonmgrv1alpha1.WorkflowType, name string) (addonmgrv1alpha1.ApplicationAssemblyPhase, string, error) {
+	phase, name, err := w.install(ctx, step, wt, name)
+	workflowSubmissions.WithLabelValues(string(phase), string(w.addon.Spec.PkgType)).Inc()
+	return phase, name, err
+}
+
+func (w *addonWorkflows) install(ctx context.Context, step addonmgrv1alpha1.LifecycleStep, wt *addonmgrv1alpha1.WorkflowType, name string) (addonmgrv1alpha1.ApplicationAssemblyPhase, string, error) {
+	if wt.NameStrategy == addonmgrv1alpha1.ExplicitName && name == "" && wt.NameTemplate == "" {
+		return addonmgrv1alpha1.Failed, name, errors.New("invalid workflow name, the Explicit nameStrategy requires a name")
+	}
+
+	reason, message, err := w.waitingOn(ctx, wt)
+	if err != nil {
+		return addonmgrv1alpha1.Failed, name, err
+	}
+	// Only what the workflow waits on now is remembered
+	for _, r := range []string{"WaitingOnDependencies", "PreconditionsUnmet"} {
+		if r != reason {
+			w.doneWaiting(r)
+		}
+	}
+	if reason != "" {
+		w.recordWaiting(reason, message)
+		return addonmgrv1alpha1.Pending, name, nil
+	}
+
+	wp, err := w.render(ctx, step, wt, name)
+	if err != nil {
+		return addonmgrv1alpha1.Failed, name, err
+	}
+
+	existing, err := w.existingWorkflow(ctx, step, wp)
+	if err != nil {
+		return addonmgrv1alpha1.Failed, name, err
+	}
+
+	// Generated names can't identify a workflow so one with the same idempotency key that another reconcile may have
+	// submitted is adopted
+	if wp.GetName() == "" && existing != nil {
+		return workflowPhase(existing), existing.GetName(), nil
+	}
+
+	return w.submit(ctx, wp, existing)
+}
+
+// waitingOn returns the reason and message of the event for what the workflow type waits on before it's submitted,
+// the reason is empty when it doesn't have to wait
+func (w *addonWorkflows) waitingOn(ctx context.Context, wt *addonmgrv1alpha1.WorkflowType) (string, string, error) {
+	if wt.WaitForDependencies {
+		satisfied, missing, err := w.CheckDependencies(ctx)
+		if err != nil {
+			return "", "", err
+		}
+		if !satisfied {
+			return "WaitingOnDependencies", fmt.Sprintf("Waiting on dependencies %s", strings.Join(missing, ", ")), nil
+		}
+	}
+
+	if len(wt.Preconditions) > 0 {
+		unmet, err := w.checkPreconditions(wt.Preconditions)
+		if err != nil {
+			return "", "", err
+		}
+		if len(unmet) > 0 {
+			return "PreconditionsUnmet", fmt.Sprintf("Waiting on API resources %s", strings.Join(unmet, ", ")), nil
+		}
+	}
+
+	return "", "", nil
+}
+
+// existingWorkflow returns the workflow submitted before for the rendered workflow, nil when there is none. Named
+// workflows are looked up by name and generated names by their idempotency key.
+func (w *addonWorkflows) existingWorkflow(ctx context.Context, step addonmgrv1alpha1.LifecycleStep, wp *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	if wp.GetName() != "" {
+		return w.findWorkflowByName(ctx, types.NamespacedName{Name: wp.GetName(), Namespace: wp.GetNamespace()})
+	}
+
+	return w.findWorkflowByAnnotation(wp.GetNamespace(), step, WorkflowIdempotencyKeyAnnotation, wp.GetAnnotations()[WorkflowIdempotencyKeyAnnotation])
+}
+
+// recordWaiting records the event of the addon waiting, it's only recorded when what the addon waits on changed since
+// its last reconcile so an addon that keeps waiting doesn't repeat it
+func (w *addonWorkflows) recordWaiting(reason, message string) {
+	key := waitingKey{addon: types.NamespacedName{Namespace: w.addon.GetNamespace(), Name: w.addon.GetName()}, reason: reason}
+
+	w.waitingMutex.Lock()
+	changed := w.waiting[key] != message
+	w.waiting[key] = message
+	w.waitingMutex.Unlock()
+
+	if changed {
+		w.recorder.Event(w.addon, "Normal", reason, message)
+	}
+}
+
+// doneWaiting forgets what the addon waited on, the event is recorded again if it has to wait again
+func (w *addonWorkflows) doneWaiting(reason string) {
+	w.waitingMutex.Lock()
+	delete(w.waiting, waitingKey{addon: types.NamespacedName{Namespace: w.addon.GetNamespace(), Name: w.addon.GetName()}, reason: reason})
+	w.waitingMutex.Unlock()
+}
+
+// checkPreconditions returns the API resource types of the preconditions the cluster doesn't serve as group/version/kind
+func (w *addonWorkflows) checkPreconditions(preconditions []addonmgrv1alpha1.APIPrecondition) ([]string, error) {
+	if w.discovery == nil {
+		return nil, errors.New("workflow preconditions require a discovery client")
+	}
+
+	// Reconciles of the addons share the discovered resources instead of asking the cluster every time
+	w.discoveryMutex.Lock()
+	defer w.discoveryMutex.Unlock()
+	if w.servedGroups == nil || time.Since(w.discoveredAt) > discoveryTTL {
+		groups, err := w.discovery.ServerGroups()
+		if err != nil {
+			return nil, fmt.Errorf("unable to discover API groups. %v", err)
+		}
+		w.servedGroups = make(map[string]bool)
+		for _, gv := range metav1.ExtractGroupVersions(groups) {
+			w.servedGroups[gv] = true
+		}
+		w.apiResources = make(map[string]*metav1.APIResourceList)
+		w.discoveredAt = time.Now()
+	}
+
+	var unmet []string
+	for _, p := range preconditions {
+		gv := schema.GroupVersion{Group: p.Group, Version: p.Version}.String()
+
+		var found bool
+		if w.servedGroups[gv] {
+			list, ok := w.apiResources[gv]
+			if !ok {
+				var err error
+				list, err = w.discovery.ServerResourcesForGroupVersion(gv)
+				if err != nil {
+					return nil, fmt.Errorf("unable to discover API resources of %s. %v", gv, err)
+				}
+				w.apiResources[gv] = list
+			}
+			for _, r := range list.APIResources {
+				// Subresources share the kind of their resource
+				if r.Kind == p.Kind && !strings.Contains(r.Name, "/") {
+					found = true
+					break
+				}
+			}
+		}
+
+		if !found {
+			unmet = append(unmet, gv+"/"+p.Kind)
+		}
+	}
+
+	return unmet, nil
+}
+
+// ListWorkflows returns the workflows submitted for the addon, most recently created first
+func (w *addonWorkflows) ListWorkflows(ctx context.Context) ([]WorkflowInfo, error) {
+	selector := fmt.Sprintf("%s=%s", WorkflowAddonLabel, w.addon.GetName())
+	workflows, err := w.dynClient.Resource(w.gvr).Namespace(w.workflowNamespace()).List(metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workflows. %v", err)
+	}
+
+	source := fmt.Sprintf("%s/%s", w.addon.GetNamespace(), w.addon.GetName())
+	var infos []WorkflowInfo
+	for i := range workflows.Items {
+		workflow := &workflows.Items[i]
+		// Addons with the same name in other namespaces may submit to the same workflow namespace
+		if s, ok := workflow.GetAnnotations()[WorkflowSourceAnnotation]; ok && s != source {
+			continue
+		}
+		infos = append(infos, newWorkflowInfo(workflow))
+	}
+	sortWorkflowInfos(infos)
+
+	return infos, nil
+}
+
+// ListByLabelSelector returns the workflows in the workflow namespace that match the selector, whichever addon they
+// were submitted for, most recently created first
+func (w *addonWorkflows) ListByLabelSelector(ctx context.Context, selector labels.Selector) ([]WorkflowInfo, error) {
+	workflows, err := w.dynClient.Resource(w.gvr).Namespace(w.workflowNamespace()).List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workflows. %v", err)
+	}
+
+	var infos []WorkflowInfo
+	for i := range workflows.Items {
+		infos = append(infos, newWorkflowInfo(&workflows.Items[i]))
+	}
+	sortWorkflowInfos(infos)
+
+	return infos, nil
+}
+
+// HealthCheck lists workflows to make sure the API server is reachable and serves the argo Workflow CRD, e.g. for a
+// readiness probe
+func (w *workflowLifecycle) HealthCheck(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Without a workflow namespace the workflows of all namespaces are listed
+	_, err := w.dynClient.Resource(w.gvr).Namespace(w.namespace).List(metav1.ListOptions{Limit: 1})
+	if err != nil && apierrors.IsNotFound(err) {
+		return fmt.Errorf("%s are not served, make sure the argo workflow CRD is installed. %v", w.gvr.GroupResource(), err)
+	} else if err != nil {
+		return fmt.Errorf("failed to list workflows. %v", err)
+	}
+
+	return nil
+}
+
+// newWorkflowInfo summarizes the workflow
+func newWorkflowInfo(workflow *unstructured.Unstructured) WorkflowInfo {
+	return WorkflowInfo{
+		Name:      workflow.GetName(),
+		Phase:     workflowPhase(workflow),
+		Type:      addonmgrv1alpha1.LifecycleStep(workflow.GetLabels()[WorkflowStepLabel]),
+		CreatedAt: workflow.GetCreationTimestamp(),
+	}
+}
+
+// sortWorkflowInfos orders the workflows most recently created first
+func sortWorkflowInfos(infos []WorkflowInfo) {
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[j].CreatedAt.Before(&infos[i].CreatedAt)
+	})
+}
+
+// FindExistingWorkflow looks up the most recent workflow of the lifecycle step that is still running for the addon, so
+// a restarted controller can adopt it instead of submitting a new one
+func (w *workflowLifecycle) FindExistingWorkflow(ctx context.Context, addonNamespace, addonName, wfType string) (string, addonmgrv1alpha1.ApplicationAssemblyPhase, bool, error) {
+	namespace := w.namespace
+	if namespace == "" {
+		namespace = addonNamespace
+	}
+
+	selector := fmt.Sprintf("%s=%s,%s=%s", WorkflowAddonLabel, addonName, WorkflowStepLabel, wfType)
+	workflows, err := w.dynClient.Resource(w.gvr).Namespace(namespace).List(metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return "", "", false, fmt.Errorf("failed to list workflows. %v", err)
+	}
+
+	source := fmt.Sprintf("%s/%s", addonNamespace, addonName)
+	var existing *unstructured.Unstructured
+	for i := range workflows.Items {
+		workflow := &workflows.Items[i]
+		if s, ok := workflow.GetAnnotations()[WorkflowSourceAnnotation]; ok && s != source {
+			continue
+		}
+		if isWorkflowCompleted(workflow) {
+			continue
+		}
+		if existing == nil || workflow.GetCreationTimestamp().Time.After(existing.GetCreationTimestamp().Time) {
+			existing = workflow
+		}
+	}
+
+	if existing == nil {
+		return "", "", false, nil
+	}
+
+	return existing.GetName(), workflowPhase(existing), true, nil
+}
+
+// ChecksumInstall returns the checksum of the workflow that Install would submit for the workflow type
+func (w *addonWorkflows) ChecksumInstall(ctx context.Context, step addonmgrv1alpha1.LifecycleStep, wt *addonmgrv1alpha1.WorkflowType) (string, error) {
+	wp, err := w.render(ctx, step, wt, "")
+	if err != nil {
+		return "", err
+	}
+
+	return wp.GetAnnotations()[WorkflowChecksumAnnotation], nil
+}
+
+// ResubmitIfChanged compares the checksum of the workflow Install would submit with the one stored on the existing
+// workflow. The existing workflow is deleted and the new one submitted only when they differ, otherwise the phase of
+// the existing workflow is returned with changed set to false. A missing existing workflow is submitted.
+func (w *addonWorkflows) ResubmitIfChanged(ctx context.Context, step addonmgrv1alpha1.LifecycleStep, wt *addonmgrv1alpha1.WorkflowType, existingWfName string) (addonmgrv1alpha1.ApplicationAssemblyPhase, string, bool, error) {
+	existing, err := w.GetWorkflow(ctx, existingWfName)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return addonmgrv1alpha1.Failed, existingWfName, false, err
+	} else if err != nil {
+		existing = nil
+	}
+
+	// A workflow named by the server is resubmitted with its generateName
+	name := existingWfName
+	if existing != nil && existing.GetGenerateName() != "" {
+		name = ""
+	}
+
+	wp, err := w.render(ctx, step, wt, name)
+	if err != nil {
+		return addonmgrv1alpha1.Failed, existingWfName, false, err
+	}
+
+	if existing != nil {
+		if existing.GetAnnotations()[WorkflowChecksumAnnotation] == wp.GetAnnotations()[WorkflowChecksumAnnotation] {
+			return workflowPhase(existing), existingWfName, false, nil
+		}
+
+		w.log.Info("workflow changed, resubmitting", "workflow", existingWfName)
+		if err := w.Delete(ctx, existingWfName); err != nil {
+			return addonmgrv1alpha1.Failed, existingWfName, false, err
+		}
+		// The replacement can't be created under the same name while the old workflow is still being deleted
+		if err := w.waitForDeletion(ctx, existingWfName, existing.GetUID()); err != nil {
+			return addonmgrv1alpha1.Failed, existingWfName, false, err
+		}
+	}
+
+	resubmitted, err := w.existingWorkflow(ctx, step, wp)
+	if err != nil {
+		return addonmgrv1alpha1.Failed, existingWfName, false, err
+	}
+	phase, newWfName, err := w.submit(ctx, wp, resubmitted)
+	workflowSubmissions.WithLabelValues(string(phase), string(w.addon.Spec.PkgType)).Inc()
+	return phase, newWfName, true, err
+}
+
+// EnsureInstalled reports the phase of the existing workflow, a workflow is only submitted when there is no existing
+// workflow name or the workflow no longer exists. Unlike Install a completed workflow is never resubmitted.
+func (w *addonWorkflows) EnsureInstalled(ctx context.Context, step addonmgrv1alpha1.LifecycleStep, wt *addonmgrv1alpha1.WorkflowType, existingWfName string) (addonmgrv1alpha1.ApplicationAssemblyPhase, string, error) {
+	if existingWfName != "" {
+		phase, err := w.GetStatus(ctx, existingWfName)
+		if err == nil {
+			return phase, existingWfName, nil
+		}
+		if !errors.Is(err, ErrWorkflowNotFound) {
+			return addonmgrv1alpha1.Failed, existingWfName, err
+		}
+	}
+
+	return w.Install(ctx, step, wt, existingWfName)
+}
+
+// DryRunInstall renders the workflow exactly as Install would submit it, without creating it
+func (w *addonWorkflows) DryRunInstall(ctx context.Context, step addonmgrv1alpha1.LifecycleStep, wt *addonmgrv1alpha1.WorkflowType, name string) (*unstructured.Unstructured, error) {
+	return w.render(ctx, step, wt, name)
+}
+
+// PlanInstall returns what Install would do with the workflow type and name without submitting anything, the
+// workflow is adopted if one with the same name, or the same idempotency key for generated names, was submitted with
+// the same checksum and created again, or applied with server-side apply, if it was submitted with another one. It
+// waits on the dependencies and preconditions Install would wait on.
+func (w *addonWorkflows) PlanInstall(ctx context.Context, step addonmgrv1alpha1.LifecycleStep, wt *addonmgrv1alpha1.WorkflowType, name string) (string, error) {
+	reason, _, err := w.waitingOn(ctx, wt)
+	if err != nil {
+		return "", err
+	}
+	if reason != "" {
+		return InstallActionWait, nil
+	}
+
+	wp, err := w.render(ctx, step, wt, name)
+	if err != nil {
+		return "", err
+	}
+
+	existing, err := w.existingWorkflow(ctx, step, wp)
+	if err != nil {
+		return "", err
+	}
+
+	if existing == nil {
+		return InstallActionCreate, nil
+	}
+
+	checksum, ok := existing.GetAnnotations()[WorkflowChecksumAnnotation]
+	switch {
+	case ok && checksum == wp.GetAnnotations()[WorkflowChecksumAnnotation]:
+		return InstallActionAdopt, nil
+	case w.apply && wp.GetName() != "":
+		return InstallActionApply, nil
+	case !ok:
+		return InstallActionSkip, nil
+	default:
+		return InstallActionCreate, nil
+	}
+}
+
+// DetectDrift compares the spec of the live workflow with the spec Install would submit for the workflow type, the
+// differences are returned by field path with a being the live and b the desired value. spec.suspend and
+// spec.shutdown are not compared as Suspend, Resume and Terminate change them.
+func (w *addonWorkflows) DetectDrift(ctx context.Context, step addonmgrv1alpha1.LifecycleStep, wt *addonmgrv1alpha1.WorkflowType, name string) (bool, string, error) {
+	live, err := w.GetWorkflow(ctx, name)
+	if err != nil && apierrors.IsNotFound(err) {
+		return false, "", fmt.Errorf("%s/%s: %w", w.workflowNamespace(), name, ErrWorkflowNotFound)
+	} else if err != nil {
+		return false, "", err
+	}
+
+	desired, err := w.render(ctx, step, wt, name)
+	if err != nil {
+		return false, "", err
+	}
+
+	liveSpec, err := comparableSpec(live)
+	if err != nil {
+		return false, "", err
+	}
+	desiredSpec, err := comparableSpec(desired)
+	if err != nil {
+		return false, "", err
+	}
+
+	if reflect.DeepEqual(liveSpec, desiredSpec) {
+		return false, "", nil
+	}
+
+	return true, strings.TrimPrefix(diff.ObjectReflectDiff(liveSpec, desiredSpec), "\n"), nil
+}
+
+// comparableSpec returns workflow.spec without the fields changed at runtime, round tripped through JSON so numbers
+// have the same type whether the workflow was rendered or read from the apiserver
+func comparableSpec(wf *unstructured.Unstructured) (map[string]interface{}, error) {
+	spec, _, err := unstructured.NestedMap(wf.UnstructuredContent(), "spec")
+	if err != nil {
+		return nil, fmt.Errorf("invalid spec in workflow %s/%s. %v", wf.GetNamespace(), wf.GetName(), err)
+	}
+	delete(spec, "suspend")
+	delete(spec, "shutdown")
+
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	comparable := make(map[string]interface{})
+	if err := json.Unmarshal(data, &comparable); err != nil {
+		return nil, err
+	}
+
+	return comparable, nil
+}
+
+// render builds the workflow from the workflow type template with the addon parameters and metadata applied
+func (w *addonWorkflows) render(ctx context.Context, step addonmgrv1alpha1.LifecycleStep, wt *addonmgrv1alpha1.WorkflowType, name string) (*unstructured.Unstructured, error) {
+	err := validateNamePrefix(wt.NamePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	switch wt.NameStrategy {
+	case "", addonmgrv1alpha1.ExplicitName:
+	case addonmgrv1alpha1.GenerateName:
+		if wt.NameTemplate != "" {
+			return nil, errors.New("invalid nameTemplate, can't be used with the Generate nameStrategy")
+		}
+		name = ""
+	default:
+		return nil, fmt.Errorf("invalid nameStrategy %q", wt.NameStrategy)
+	}
+
+	if wt.NameTemplate != "" {
+		name, err = w.renderWorkflowName(wt.NameTemplate, step)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	wp := &unstructured.Unstructured{}
+	err = w.parse(wt, wp, name)
+	if err != nil {
+		return nil, fmt.Errorf("%w. %v", ErrInvalidTemplate, err)
+	}
+
+	err = addon.ValidatePkgVersion(w.addon.Spec.PkgVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validatePackageParams(w.addon.Spec.PkgParams)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validatePackageDeps(w.addon.Spec.PkgDeps)
+	if err != nil {
+		return nil, err
+	}
+
+	if !w.configureGlobalWFParameters(w.addon, wp) {
+		return nil, errors.New("invalid workflow parameter")
+	}
+
+	err = w.configureParamsFromConfigMap(ctx, wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configureWorkflowArtifacts(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configureWorkflowTTL(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configureActiveDeadline(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configureServiceAccount(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configureImagePullSecrets(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configureRetryStrategy(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configureParallelism(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configurePodResources(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configureEnv(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configureMetrics(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configureSecrets(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configureVolumes(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configureInitContainers(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configureRegistryMirror(wp)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configureNodeScheduling(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	if ref := wt.ArtifactRepositoryRef; ref != nil && ref.ConfigMap != "" {
+		artifactRepositoryRef := map[string]interface{}{"configMap": ref.ConfigMap}
+		if ref.Key != "" {
+			artifactRepositoryRef["key"] = ref.Key
+		}
+		err = unstructured.SetNestedMap(wp.UnstructuredContent(), artifactRepositoryRef, "spec", "artifactRepositoryRef")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = w.configurePodGC(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configurePodAnnotations(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configureSecurityContext(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configureHostAliases(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configureTemplateDefaults(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configureOnExit(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configureSynchronization(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configureImagePullPolicy(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.configureSuspendDuration(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	if wt.ArchiveLogs != nil {
+		err = unstructured.SetNestedField(wp.UnstructuredContent(), *wt.ArchiveLogs, "spec", "archiveLogs")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if wt.PriorityClassName != "" {
+		err = mergePodSpecPatch(wp, map[string]interface{}{"priorityClassName": wt.PriorityClassName})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if wt.PodSpecPatch != "" {
+		patch := make(map[string]interface{})
+		if err := yaml.Unmarshal([]byte(wt.PodSpecPatch), &patch); err != nil {
+			return nil, fmt.Errorf("invalid podSpecPatch. %v", err)
+		}
+		err = mergePodSpecPatch(wp, patch)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if wt.SuspendOnStart {
+		// A suspended workflow does not start any steps until it is resumed
+		err = unstructured.SetNestedField(wp.UnstructuredContent(), true, "spec", "suspend")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	w.configureWorkflowMetadata(wp, step)
+
+	checksum, err := workflowChecksum(wp, wt)
+	if err != nil {
+		return nil, err
+	}
+	annotations := wp.GetAnnotations()
+	annotations[WorkflowChecksumAnnotation] = checksum
+	annotations[WorkflowIdempotencyKeyAnnotation] = workflowIdempotencyKey(w.addon, step, checksum)
+	wp.SetAnnotations(annotations)
+
+	return wp, nil
+}
+
+// workflowChecksum hashes the rendered workflow spec together with its generated name and role
+func workflowChecksum(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) (string, error) {
+	data, err := json.Marshal(map[string]interface{}{
+		"generateName": wf.GetGenerateName(),
+		"role":         wt.Role,
+		"spec":         wf.UnstructuredContent()["spec"],
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", adler32.Checksum(data)), nil
+}
+
+// findWorkflowByAnnotation returns the addon workflow of the lifecycle step carrying the annotation value, or nil if
+// there is none
+func (w *addonWorkflows) findWorkflowByAnnotation(namespace string, step addonmgrv1alpha1.LifecycleStep, annotation, value string) (*unstructured.Unstructured, error) {
+	selector := fmt.Sprintf("%s=%s", WorkflowAddonLabel, w.addon.GetName())
+	if step != "" {
+		selector = fmt.Sprintf("%s,%s=%s", selector, WorkflowStepLabel, step)
+	}
+	workflows, err := w.dynClient.Resource(w.gvr).Namespace(namespace).List(metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range workflows.Items {
+		if workflows.Items[i].GetAnnotations()[annotation] == value {
+			return &workflows.Items[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// workflowIdempotencyKey identifies the workflows submitted for the addon step with the same spec and workflow type,
+// the workflow type is covered by the checksum of the rendered workflow. Steps running the same template get
+// different keys so one doesn't adopt the workflow of the other.
+func workflowIdempotencyKey(addon *addonmgrv1alpha1.Addon, step addonmgrv1alpha1.LifecycleStep, checksum string) string {
+	data := strings.Join([]string{string(addon.GetUID()), addon.CalculateChecksum(), string(step), checksum}, "/")
+	return fmt.Sprintf("%x", adler32.Checksum([]byte(data)))
+}
+
+// Appends addon.spec.params to workflow.spec.arguments.parameters
+func (w *addonWorkflows) configureGlobalWFParameters(addon *addonmgrv1alpha1.Addon, wf *unstructured.Unstructured) bool {
+	// get workflow argument parameters
+	spec, _ := wf.UnstructuredContent()["spec"].(map[string]interface{})
+	if spec["arguments"] == nil {
+		spec["arguments"] = make(map[string]interface{})
+	}
+
+	arguments := spec["arguments"].(map[string]interface{})
+	if arguments["parameters"] == nil {
+		arguments["parameters"] = make([]interface{}, 0)
+	}
+
+	wfParams := arguments["parameters"].([]interface{})
+	if wfParams == nil {
+		arguments["parameters"] = make([]interface{}, 0)
+	}
+
+	// get addon params
+	namespaceParam := addon.Spec.Params.Namespace
+	contextParams := addon.Spec.Params.Context
+	dataParams := addon.Spec.Params.Data
+
+	namespaceMap := make(map[string]interface{})
+	namespaceMap["name"] = "namespace"
+	namespaceMap["value"] = namespaceParam
+
+	wfParams = append(wfParams, namespaceMap)
+
+	// Package type lets templates branch on the deployer, e.g. helm or kustomize, a pkgType the template declares wins
+	if addon.Spec.PkgType != "" && !hasParameter(wfParams, "pkgType") {
+		pkgTypeMap := make(map[string]interface{})
+		pkgTypeMap["name"] = "pkgType"
+		pkgTypeMap["value"] = string(addon.Spec.PkgType)
+
+		wfParams = append(wfParams, pkgTypeMap)
+	}
+
+	// Copy general Context string params to global workflow variables (clusterName and clusterRegion currently)
+	cp := reflect.ValueOf(contextParams)
+	for i := 0; i < cp.Type().NumField(); i++ {
+		contextMap := make(map[string]interface{})
+		kind := cp.Field(i).Kind()
+		if kind == reflect.String {
+			fieldName := cp.Type().Field(i).Name
+			tag := cp.Type().Field(i).Tag
+			jsonTag := strings.Split(tag.Get("json"), ",")[0]
+			contextMap["name"] = jsonTag
+			contextMap["value"] = cp.FieldByName(fieldName).String()
+			wfParams = append(wfParams, contextMap)
+		}
+	}
+
+	// Map params are added in name order so the rendered workflow is stable
+	var names []string
+
+	// Copy AdditionalConfigs from Context to global workflow variables
+	for name := range contextParams.AdditionalConfigs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		addParam := make(map[string]interface{})
+		addParam["name"] = name
+		addParam["value"] = string(contextParams.AdditionalConfigs[name])
+		wfParams = append(wfParams, addParam)
+	}
+
+	// Copy stringParams to global workflow variables
+	names = names[:0]
+	for name := range dataParams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		addParam := make(map[string]interface{})
+		addParam["name"] = name
+		addParam["value"] = string(dataParams[name])
+		wfParams = append(wfParams, addParam)
+	}
+
+	// Copy package params to global workflow variables
+	names = names[:0]
+	for name := range addon.Spec.PkgParams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		addParam := make(map[string]interface{})
+		addParam["name"] = name
+		addParam["value"] = addon.Spec.PkgParams[name]
+		wfParams = append(wfParams, addParam)
+	}
+
+	err := unstructured.SetNestedSlice(wf.UnstructuredContent(), wfParams, "spec", "arguments", "parameters")
+	if err != nil {
+		return false
+	}
+
+	return true
+}
+
+// hasParameter reports whether the workflow parameters have one with the name
+func hasParameter(params []interface{}, name string) bool {
+	for _, param := range params {
+		if param, ok := param.(map[string]interface{}); ok && param["name"] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Adds the keys of the workflow type config map to the global workflow parameters, parameters already set by the
+// template or the addon are kept
+func (w *addonWorkflows) configureParamsFromConfigMap(ctx context.Context, wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	ref := wt.ParamsFromConfigMap
+	if ref == nil {
+		return nil
+	}
+
+	key := types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}
+	if key.Namespace == "" {
+		key.Namespace = w.addon.GetNamespace()
+	}
+	cm := &corev1.ConfigMap{}
+	if err := w.Get(ctx, key, cm); err != nil {
+		return fmt.Errorf("unable to get paramsFromConfigMap %s. %v", key, err)
+	}
+	w.redactSecretParams(cm.Data)
+
+	params, _, err := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "arguments", "parameters")
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool)
+	for _, param := range params {
+		if param, ok := param.(map[string]interface{}); ok {
+			if name, ok := param["name"].(string); ok {
+				names[name] = true
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(cm.Data))
+	for k := range cm.Data {
+		if !paramNameRegexp.MatchString(k) {
+			return fmt.Errorf("invalid parameter name %q in paramsFromConfigMap %s", k, key)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !names[k] {
+			params = append(params, map[string]interface{}{"name": k, "value": cm.Data[k]})
+		}
+	}
+
+	return unstructured.SetNestedSlice(wf.UnstructuredContent(), params, "spec", "arguments", "parameters")
+}
+
+// validateNamePrefix makes sure the name prefix can be used in a workflow name
+func validateNamePrefix(prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+
+	if len(prefix) > maxNamePrefixLength {
+		return fmt.Errorf("invalid namePrefix %q, must be no more than %d characters", prefix, maxNamePrefixLength)
+	}
+
+	if !namePrefixRegexp.MatchString(prefix) {
+		return fmt.Errorf("invalid namePrefix %q, must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character", prefix)
+	}
+
+	return nil
+}
+
+// workflowNameData holds the placeholders of the workflow type name template
+type workflowNameData struct {
+	PkgName    string
+	PkgVersion string
+	Type       string
+
+	checksum     string
+	checksumUsed bool
+}
+
+// Checksum is the addon checksum placeholder, it records that the template names the workflow by the checksum
+func (d *workflowNameData) Checksum() string {
+	d.checksumUsed = true
+	return d.checksum
+}
+
+// renderWorkflowName renders the workflow name from the name template, the result is sanitized to a DNS-1123 label.
+// Workflows are resubmitted when the addon changes because the name has the addon checksum, it's appended to the name
+// if the template doesn't use it.
+func (w *addonWorkflows) renderWorkflowName(nameTemplate string, step addonmgrv1alpha1.LifecycleStep) (string, error) {
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(nameTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid nameTemplate %q. %v", nameTemplate, err)
+	}
+
+	var b strings.Builder
+	data := &workflowNameData{
+		PkgName:    w.addon.Spec.PkgName,
+		PkgVersion: w.addon.Spec.PkgVersion,
+		Type:       string(step),
+		checksum:   w.addon.CalculateChecksum(),
+	}
+	err = tmpl.Execute(&b, data)
+	if err != nil {
+		return "", fmt.Errorf("invalid nameTemplate %q. %v", nameTemplate, err)
+	}
+
+	name := sanitizeName(b.String())
+	if !data.checksumUsed {
+		name = fmt.Sprintf("%s-%s", name, data.checksum)
+	}
+	if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+		return "", fmt.Errorf("invalid nameTemplate %q, rendered name %q %s", nameTemplate, name, strings.Join(errs, ", "))
+	}
+
+	return name, nil
+}
+
+// validatePackageParams makes sure package param names are valid argo parameter names
+func validatePackageParams(params map[string]string) error {
+	for name := range params {
+		if !paramNameRegexp.MatchString(name) {
+			return fmt.Errorf("invalid package parameter name %q, must match %s", name, paramNameRegexp.String())
+		}
+	}
+	return nil
+}
+
+// validatePackageDeps makes sure package dependency versions are valid constraints
+func validatePackageDeps(deps map[string]string) error {
+	for pkgName, pkgVersion := range deps {
+		if err := addon.ValidateConstraint(pkgVersion); err != nil {
+			return fmt.Errorf("%w, invalid package dependency %s. %v", ErrDependenciesUnsatisfied, pkgName, err)
+		}
+	}
+	return nil
+}
+
+// Delete removes the workflow, a workflow that no longer exists is not an error
+func (w *addonWorkflows) Delete(ctx context.Context, name string) error {
+	err := w.DeleteStrict(ctx, name)
+	if err != nil && apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// DeleteStrict removes the workflow and returns an error if it does not exist
+func (w *addonWorkflows) DeleteStrict(ctx context.Context, name string) error {
+	// The dynamic client does not accept a context, stop before calling the apiserver once it is done
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	w.log.V(1).Info("deleting workflow", "workflow", name, "namespace", w.workflowNamespace())
+
+	err := w.dynClient.Resource(w.gvr).Namespace(w.workflowNamespace()).Delete(name, &metav1.DeleteOptions{})
+	if err != nil {
+		return err
+	}
+	w.recorder.Event(w.addon, "Normal", "WorkflowDeleted", fmt.Sprintf("Deleted workflow %s for addon %s/%s", name, w.addon.Namespace, w.addon.Name))
+	return nil
+}
+
+// ForceDelete clears the workflow finalizers before removing it, so a workflow stuck terminating is removed even when
+// the argo controller is down. It should only be used once a regular delete did not complete.
+func (w *addonWorkflows) ForceDelete(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	w.log.Info("clearing workflow finalizers", "workflow", name, "namespace", w.workflowNamespace())
+
+	patch := []byte(`{"metadata":{"finalizers":null}}`)
+	_, err := w.dynClient.Resource(w.gvr).Namespace(w.workflowNamespace()).Patch(name, types.MergePatchType, patch, metav1.PatchOptions{FieldManager: w.fieldManager})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("%s/%s: %w", w.workflowNamespace(), name, ErrWorkflowNotFound)
+		}
+		return err
+	}
+
+	// A workflow that was already terminating is removed once its finalizers are cleared
+	return w.Delete(ctx, name)
+}
+
+// DeleteByAddon removes every workflow submitted for the addon, workflows that no longer exist are not an error
+func (w *addonWorkflows) DeleteByAddon(ctx context.Context) error {
+	workflows, err := w.ListWorkflows(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, workflow := range workflows {
+		if err := w.Delete(ctx, workflow.Name); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete workflow %s/%s. %v", w.workflowNamespace(), workflow.Name, err))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// Suspend pauses a running workflow by setting workflow.spec.suspend
+func (w *addonWorkflows) Suspend(ctx context.Context, name string) error {
+	return w.setSuspend(ctx, name, true)
+}
+
+// Resume continues a previously suspended workflow by clearing workflow.spec.suspend
+func (w *addonWorkflows) Resume(ctx context.Context, name string) error {
+	return w.setSuspend(ctx, name, false)
+}
+
+func (w *addonWorkflows) setSuspend(ctx context.Context, name string, suspend bool) error {
+	// The dynamic client doesn't take a context, stop before reading and patching the workflow once it is done
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	workflow, err := w.dynClient.Resource(w.gvr).Namespace(w.workflowNamespace()).Get(name, metav1.GetOptions{})
+	if err != nil && apierrors.IsNotFound(err) {
+		return fmt.Errorf("%s/%s: %w", w.workflowNamespace(), name, ErrWorkflowNotFound)
+	} else if err != nil {
+		return err
+	}
+
+	if isWorkflowCompleted(workflow) {
+		return fmt.Errorf("%s/%s: %w", w.workflowNamespace(), name, ErrWorkflowCompleted)
+	}
+
+	// Nothing to do if the workflow is already in the requested state
+	suspended, _, _ := unstructured.NestedBool(workflow.UnstructuredContent(), "spec", "suspend")
+	if suspended == suspend {
+		return nil
+	}
+
+	// A nil suspend is how argo resumes a workflow
+	var value interface{}
+	reason := "Resumed"
+	if suspend {
+		value = true
+		reason = "Suspended"
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{"spec": map[string]interface{}{"suspend": value}})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.dynClient.Resource(w.gvr).Namespace(w.workflowNamespace()).Patch(name, types.MergePatchType, patch, metav1.PatchOptions{FieldManager: w.fieldManager})
+	if err != nil {
+		return err
+	}
+
+	w.recorder.Event(w.addon, "Normal", reason, fmt.Sprintf("%s Workflow %s/%s", reason, w.workflowNamespace(), name))
+
+	return nil
+}
+
+// Terminate stops a running workflow by setting workflow.spec.shutdown, unlike Delete the workflow is kept for inspection
+func (w *addonWorkflows) Terminate(ctx context.Context, name string) error {
+	workflow, err := w.GetWorkflow(ctx, name)
+	if err != nil && apierrors.IsNotFound(err) {
+		return fmt.Errorf("%s/%s: %w", w.workflowNamespace(), name, ErrWorkflowNotFound)
+	} else if err != nil {
+		return err
+	}
+
+	if isWorkflowCompleted(workflow) {
+		return fmt.Errorf("%s/%s: %w", w.workflowNamespace(), name, ErrWorkflowCompleted)
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{"spec": map[string]interface{}{"shutdown": "Terminate"}})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.dynClient.Resource(w.gvr).Namespace(w.workflowNamespace()).Patch(name, types.MergePatchType, patch, metav1.PatchOptions{FieldManager: w.fieldManager})
+	if err != nil {
+		return err
+	}
+
+	w.recorder.Event(w.addon, "Normal", "Terminated", fmt.Sprintf("Terminated Workflow %s/%s", w.workflowNamespace(), name))
+
+	return nil
+}
+
+// AddWorkflowLabels merges the labels into the workflow labels, e.g. to tag metadata learned after submission. CRDs don't
+// support strategic merge patches, a merge patch updates the labels the same way.
+func (w *addonWorkflows) AddWorkflowLabels(ctx context.Context, name string, labels map[string]string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+
+	for k, v := range labels {
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			return fmt.Errorf("invalid label key %q. %s", k, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsValidLabelValue(v); len(errs) > 0 {
+			return fmt.Errorf("invalid label value %q. %s", v, strings.Join(errs, ", "))
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{"labels": labels}})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.dynClient.Resource(w.gvr).Namespace(w.workflowNamespace()).Patch(name, types.MergePatchType, patch, metav1.PatchOptions{FieldManager: w.fieldManager})
+	if err != nil && apierrors.IsNotFound(err) {
+		return fmt.Errorf("%s/%s: %w", w.workflowNamespace(), name, ErrWorkflowNotFound)
+	}
+
+	return err
+}
+
+// IsWorkflowExpired reports whether a workflow that has not completed was started more than max ago and should be
+// terminated. A max of zero or less never expires workflows.
+func (w *addonWorkflows) IsWorkflowExpired(ctx context.Context, name string, max time.Duration) (bool, error) {
+	workflow, err := w.GetWorkflow(ctx, name)
+	if err != nil && apierrors.IsNotFound(err) {
+		return false, fmt.Errorf("%s/%s: %w", w.workflowNamespace(), name, ErrWorkflowNotFound)
+	} else if err != nil {
+		return false, err
+	}
+
+	started := workflowStartTime(workflow)
+	if max <= 0 || started.IsZero() || isWorkflowCompleted(workflow) {
+		return false, nil
+	}
+
+	return time.Since(started) > max, nil
+}
+
+// RunningDuration returns how long the workflow has been running, from status.startedAt or the creation time if it
+// has not started yet. Completed workflows report the time they ran until status.finishedAt.
+func (w *addonWorkflows) RunningDuration(ctx context.Context, name string) (time.Duration, error) {
+	workflow, err := w.GetWorkflow(ctx, name)
+	if err != nil && apierrors.IsNotFound(err) {
+		return 0, fmt.Errorf("%s/%s: %w", w.workflowNamespace(), name, ErrWorkflowNotFound)
+	} else if err != nil {
+		return 0, err
+	}
+
+	started := workflowStartTime(workflow)
+	if started.IsZero() {
+		return 0, nil
+	}
+
+	end := time.Now()
+	if finishedAt, _, _ := unstructured.NestedString(workflow.UnstructuredContent(), "status", "finishedAt"); finishedAt != "" {
+		if t, err := time.Parse(time.RFC3339, finishedAt); err == nil {
+			end = t
+		}
+	}
+
+	return end.Sub(started), nil
+}
+
+// workflowStartTime returns workflow.status.startedAt, falling back to the creation time for workflows that have not
+// started
+func workflowStartTime(workflow *unstructured.Unstructured) time.Time {
+	if startedAt, _, _ := unstructured.NestedString(workflow.UnstructuredContent(), "status", "startedAt"); startedAt != "" {
+		if t, err := time.Parse(time.RFC3339, startedAt); err == nil {
+			return t
+		}
+	}
+
+	return workflow.GetCreationTimestamp().Time
+}
+
+// GetStatus maps the argo workflow phase to the addon phase
+func (w *addonWorkflows) GetStatus(ctx context.Context, name string) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
+	workflow, err := w.GetWorkflow(ctx, name)
+	if err != nil && apierrors.IsNotFound(err) {
+		return addonmgrv1alpha1.Failed, fmt.Errorf("%s/%s: %w", w.workflowNamespace(), name, ErrWorkflowNotFound)
+	} else if err != nil {
+		return addonmgrv1alpha1.Failed, err
+	}
+
+	return workflowPhase(workflow), nil
+}
+
+// IsSucceeded reports whether the workflow succeeded, a missing workflow returns ErrWorkflowNotFound
+func (w *addonWorkflows) IsSucceeded(ctx context.Context, name string) (bool, error) {
+	phase, err := w.GetStatus(ctx, name)
+	if err != nil {
+		return false, err
+	}
+
+	return phase == addonmgrv1alpha1.Succeeded, nil
+}
+
+// GetWorkflow returns the workflow object, a missing workflow returns an error that satisfies apierrors.IsNotFound
+func (w *addonWorkflows) GetWorkflow(ctx context.Context, name string) (*unstructured.Unstructured, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return w.dynClient.Resource(w.gvr).Namespace(w.workflowNamespace()).Get(name, metav1.GetOptions{})
+}
+
+// GetOutputs returns the workflow output parameters from workflow.status.outputs.parameters by name
+func (w *addonWorkflows) GetOutputs(ctx context.Context, name string) (map[string]string, error) {
+	workflow, err := w.GetWorkflow(ctx, name)
+	if err != nil && apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("%s/%s: %w", w.workflowNamespace(), name, ErrWorkflowNotFound)
+	} else if err != nil {
+		return nil, err
+	}
+
+	outputs := make(map[string]string)
+	params, _, err := unstructured.NestedSlice(workflow.UnstructuredContent(), "status", "outputs", "parameters")
+	if err != nil {
+		return nil, fmt.Errorf("invalid outputs in workflow %s/%s. %v", workflow.GetNamespace(), name, err)
+	}
+
+	for _, param := range params {
+		param, ok := param.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		paramName, _ := param["name"].(string)
+		if paramName == "" || param["value"] == nil {
+			continue
+		}
+		outputs[paramName] = fmt.Sprint(param["value"])
+	}
+
+	return outputs, nil
+}
+
+// GetFailedNodeMessages returns the message of every failed or errored node in workflow.status.nodes by node name
+func (w *addonWorkflows) GetFailedNodeMessages(ctx context.Context, name string) (map[string]string, error) {
+	workflow, err := w.GetWorkflow(ctx, name)
+	if err != nil && apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("%s/%s: %w", w.workflowNamespace(), name, ErrWorkflowNotFound)
+	} else if err != nil {
+		return nil, err
+	}
+
+	nodes, _, err := unstructured.NestedMap(workflow.UnstructuredContent(), "status", "nodes")
+	if err != nil {
+		return nil, fmt.Errorf("invalid nodes in workflow %s/%s. %v", workflow.GetNamespace(), name, err)
+	}
+
+	messages := make(map[string]string)
+	for id, node := range nodes {
+		node, ok := node.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if phase, _ := node["phase"].(string); phase != "Failed" && phase != "Error" {
+			continue
+		}
+		nodeName, _ := node["name"].(string)
+		if nodeName == "" {
+			nodeName = id
+		}
+		messages[nodeName], _ = node["message"].(string)
+	}
+
+	return messages, nil
+}
+
+// Progress returns how many of the pod nodes in workflow.status.nodes have completed and the total number of pod
+// nodes, a workflow without nodes reports 0/0
+func (w *addonWorkflows) Progress(ctx context.Context, name string) (completed, total int, err error) {
+	workflow, err := w.GetWorkflow(ctx, name)
+	if err != nil && apierrors.IsNotFound(err) {
+		return 0, 0, fmt.Errorf("%s/%s: %w", w.workflowNamespace(), name, ErrWorkflowNotFound)
+	} else if err != nil {
+		return 0, 0, err
+	}
+
+	nodes, _, err := unstructured.NestedMap(workflow.UnstructuredContent(), "status", "nodes")
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid nodes in workflow %s/%s. %v", workflow.GetNamespace(), name, err)
+	}
+
+	for _, node := range nodes {
+		node, ok := node.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if nodeType, _ := node["type"].(string); nodeType != "Pod" {
+			continue
+		}
+		total++
+		switch phase, _ := node["phase"].(string); phase {
+		case "Succeeded", "Failed", "Error", "Skipped", "Omitted":
+			completed++
+		}
+	}
+
+	return completed, total, nil
+}
+
+// WorkflowConditions translates the workflow phase, message and status.conditions into the Installed, Progressing and
+// Degraded addon conditions. A workflow without any status yet has no conditions. LastTransitionTime is left for the
+// caller to set when it updates the addon status.
+func (w *addonWorkflows) WorkflowConditions(ctx context.Context, name string) ([]addonmgrv1alpha1.Condition, error) {
+	workflow, err := w.GetWorkflow(ctx, name)
+	if err != nil && apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("%s/%s: %w", w.workflowNamespace(), name, ErrWorkflowNotFound)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return workflowConditions(workflow), nil
+}
+
+func workflowConditions(workflow *unstructured.Unstructured) []addonmgrv1alpha1.Condition {
+	conditions := []addonmgrv1alpha1.Condition{}
+
+	phase, _, _ := unstructured.NestedString(workflow.UnstructuredContent(), "status", "phase")
+	message, _, _ := unstructured.NestedString(workflow.UnstructuredContent(), "status", "message")
+	argoConditions, _, _ := unstructured.NestedSlice(workflow.UnstructuredContent(), "status", "conditions")
+	if phase == "" && len(argoConditions) == 0 {
+		return conditions
+	}
+
+	completed := phase == "Succeeded" || phase == "Failed" || phase == "Error"
+	degraded := newCondition(addonmgrv1alpha1.ConditionDegraded, phase == "Failed" || phase == "Error", "Workflow"+phase, message)
+	for _, c := range argoConditions {
+		c, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		conditionType, _ := c["type"].(string)
+		conditionStatus, _ := c["status"].(string)
+		conditionMessage, _ := c["message"].(string)
+		switch conditionType {
+		case "Completed":
+			completed = completed || conditionStatus == string(corev1.ConditionTrue)
+		case "SpecWarning", "SpecError", "MetricsError":
+			// A failed workflow is reported over its warnings
+			if conditionStatus == string(corev1.ConditionTrue) && degraded.Status != corev1.ConditionTrue {
+				degraded = newCondition(addonmgrv1alpha1.ConditionDegraded, true, conditionType, conditionMessage)
+			}
+		}
+	}
+
+	conditions = append(conditions,
+		newCondition(addonmgrv1alpha1.ConditionInstalled, phase == "Succeeded", "Workflow"+phase, message),
+		newCondition(addonmgrv1alpha1.ConditionProgressing, !completed, "Workflow"+phase, message),
+		degraded,
+	)
+
+	return conditions
+}
+
+func newCondition(conditionType addonmgrv1alpha1.ConditionType, status bool, reason, message string) addonmgrv1alpha1.Condition {
+	condition := addonmgrv1alpha1.Condition{
+		Type:    conditionType,
+		Status:  corev1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	}
+	if status {
+		condition.Status = corev1.ConditionTrue
+	}
+	return condition
+}
+
+// Retry re-submits a failed workflow by recreating it from its stored spec, under the same name once the failed
+// workflow is deleted
+func (w *addonWorkflows) Retry(ctx context.Context, name string) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
+	workflow, err := w.dynClient.Resource(w.gvr).Namespace(w.workflowNamespace()).Get(name, metav1.GetOptions{})
+	if err != nil && apierrors.IsNotFound(err) {
+		return addonmgrv1alpha1.Failed, fmt.Errorf("%s/%s: %w", w.workflowNamespace(), name, ErrWorkflowNotFound)
+	} else if err != nil {
+		return addonmgrv1alpha1.Failed, err
+	}
+
+	if phase := workflowPhase(workflow); phase != addonmgrv1alpha1.Failed {
+		return phase, fmt.Errorf("unable to retry workflow %s/%s in phase %s", w.workflowNamespace(), name, phase)
+	}
+
+	// Keep the identity and spec of the workflow, everything else is set by the server
+	retry := &unstructured.Unstructured{}
+	retry.SetGroupVersionKind(workflow.GroupVersionKind())
+	retry.SetNamespace(workflow.GetNamespace())
+	retry.SetName(workflow.GetName())
+	retry.SetLabels(workflow.GetLabels())
+	retry.SetAnnotations(workflow.GetAnnotations())
+	retry.SetOwnerReferences(workflow.GetOwnerReferences())
+	retry.UnstructuredContent()["spec"] = workflow.UnstructuredContent()["spec"]
+
+	err = w.dynClient.Resource(w.gvr).Namespace(w.workflowNamespace()).Delete(name, &metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return addonmgrv1alpha1.Failed, err
+	}
+
+	// The retry reuses the name, so it can only be created once the failed workflow is gone
+	if err := w.waitForDeletion(ctx, name, workflow.GetUID()); err != nil {
+		return addonmgrv1alpha1.Failed, err
+	}
+
+	_, err = w.dynClient.Resource(w.gvr).Namespace(w.workflowNamespace()).Create(retry, metav1.CreateOptions{FieldManager: w.fieldManager})
+	if err != nil {
+		return addonmgrv1alpha1.Failed, err
+	}
+
+	w.recorder.Event(w.addon, "Normal", "Retried", fmt.Sprintf("Retried Workflow %s/%s", w.workflowNamespace(), name))
+
+	return addonmgrv1alpha1.Pending, nil
+}
+
+// waitForDeletion polls until the workflow with the uid is gone, the workflow can linger while its finalizers run
+func (w *addonWorkflows) waitForDeletion(ctx context.Context, name string, uid types.UID) error {
+	err := poll(ctx, pollBackoff(w.pollInterval), func() (bool, error) {
+		workflow, err := w.dynClient.Resource(w.gvr).Namespace(w.workflowNamespace()).Get(name, metav1.GetOptions{})
+		if err != nil && apierrors.IsNotFound(err) {
+			return true, nil
+		} else if err != nil {
+			return false, err
+		}
+		return workflow.GetUID() != uid, nil
+	})
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("workflow %s/%s is still being deleted. %w", w.workflowNamespace(), name, err)
+	}
+
+	return err
+}
+
+// InstallLifecycle submits the prereqs workflow and waits for it to succeed before submitting the install workflow.
+// The install workflow is not submitted if the prereqs workflow fails or the context is done while waiting on it.
+func (w *addonWorkflows) InstallLifecycle(ctx context.Context, prereq, install *addonmgrv1alpha1.WorkflowType) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
+	if prereq != nil && (prereq.Template != "" || prereq.TemplateRef != "") {
+		if prereq.WaitForDependencies {
+			// A prereqs workflow held back by its dependencies isn't submitted, so there is nothing to wait on yet
+			satisfied, _, err := w.CheckDependencies(ctx)
+			if err != nil {
+				return addonmgrv1alpha1.Failed, err
+			}
+			if !satisfied {
+				phase, _, err := w.Install(ctx, addonmgrv1alpha1.Prereqs, prereq, w.addon.GetFormattedWorkflowName(addonmgrv1alpha1.Prereqs))
+				return phase, err
+			}
+		}
+
+		phase, name, err := w.Install(ctx, addonmgrv1alpha1.Prereqs, prereq, w.addon.GetFormattedWorkflowName(addonmgrv1alpha1.Prereqs))
+		if err != nil {
+			return addonmgrv1alpha1.Failed, err
+		}
+
+		if phase != addonmgrv1alpha1.Succeeded && phase != addonmgrv1alpha1.Failed {
+			phase, err = w.WaitForCompletion(ctx, name, w.pollInterval)
+			if err != nil {
+				return addonmgrv1alpha1.Failed, err
+			}
+		}
+
+		if phase == addonmgrv1alpha1.Failed {
+			return addonmgrv1alpha1.Failed, fmt.Errorf("prereqs workflow %s/%s failed", w.workflowNamespace(), name)
+		}
+	}
+
+	phase, _, err := w.Install(ctx, addonmgrv1alpha1.Install, install, w.addon.GetFormattedWorkflowName(addonmgrv1alpha1.Install))
+	return phase, err
+}
+
+// RunDeleteWorkflow submits the addon delete workflow and reports its phase, the addon finalizer should only be removed
+// once it has Succeeded. A failed delete workflow is reported as DeleteFailed. When the workflow type waits for
+// dependents it stays Pending while other addons depend on the addon package.
+func (w *addonWorkflows) RunDeleteWorkflow(ctx context.Context, wt *addonmgrv1alpha1.WorkflowType) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
+	if wt != nil && wt.WaitForDependents {
+		present, dependents, err := w.DependentsPresent(ctx)
+		if err != nil {
+			return addonmgrv1alpha1.DeleteFailed, err
+		}
+		if present {
+			w.recordWaiting("WaitingOnDependents", fmt.Sprintf("Waiting on dependents %s to be deleted", strings.Join(dependents, ", ")))
+			return addonmgrv1alpha1.Pending, nil
+		}
+		w.doneWaiting("WaitingOnDependents")
+	}
+
+	if wt == nil || (wt.Template == "" && wt.TemplateRef == "") {
+		// No delete workflow was provided
+		return addonmgrv1alpha1.Succeeded, nil
+	}
+
+	// Every reconcile of the deleted addon has to find the same delete workflow
+	if wt.NameStrategy == addonmgrv1alpha1.GenerateName {
+		return addonmgrv1alpha1.DeleteFailed, errors.New("invalid nameStrategy, delete workflows must use an Explicit name")
+	}
+
+	phase, _, err := w.Install(ctx, addonmgrv1alpha1.Delete, wt, w.addon.GetFormattedWorkflowName(addonmgrv1alpha1.Delete))
+	if err != nil {
+		return addonmgrv1alpha1.DeleteFailed, err
+	}
+
+	if phase == addonmgrv1alpha1.Failed {
+		return addonmgrv1alpha1.DeleteFailed, nil
+	}
+
+	return phase, nil
+}
+
+// OnPhaseChange registers a hook called by WaitForCompletion on every observed phase change. Hooks run synchronously
+// in the polling loop in the order they were registered, a hook that does slow work should hand it off to a goroutine.
+func (w *workflowLifecycle) OnPhaseChange(hook PhaseChangeFunc) {
+	w.hooksMutex.Lock()
+	defer w.hooksMutex.Unlock()
+
+	w.phaseHooks = append(w.phaseHooks, hook)
+}
+
+// notifyPhaseChange calls the registered phase hooks
+func (w *addonWorkflows) notifyPhaseChange(name string, from, to addonmgrv1alpha1.ApplicationAssemblyPhase) {
+	w.hooksMutex.RLock()
+	hooks := w.phaseHooks
+	w.hooksMutex.RUnlock()
+
+	for _, hook := range hooks {
+		hook(w.addon, name, from, to)
+	}
+}
+
+// WaitForCompletion polls the workflow status until it succeeds or fails, backing off from the poll interval. If the
+// context is done first the last observed phase is returned along with the context error.
+func (w *addonWorkflows) WaitForCompletion(ctx context.Context, name string, pollInterval time.Duration) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
+	var last addonmgrv1alpha1.ApplicationAssemblyPhase
+	err := poll(ctx, pollBackoff(pollInterval), func() (bool, error) {
+		phase, err := w.GetStatus(ctx, name)
+		if err != nil {
+			return false, err
+		}
+		if phase != last {
+			w.log.V(1).Info("workflow phase changed", "workflow", name, "from", last, "to", phase)
+			w.notifyPhaseChange(name, last, phase)
+			last = phase
+		}
+		return phase == addonmgrv1alpha1.Succeeded || phase == addonmgrv1alpha1.Failed, nil
+	})
+	if err != nil && ctx.Err() != nil {
+		return last, ctx.Err()
+	} else if err != nil {
+		return addonmgrv1alpha1.Failed, err
+	}
+
+	return last, nil
+}
+
+// pollBackoff backs off exponentially from the poll interval up to maxPollInterval
+func pollBackoff(pollInterval time.Duration) wait.Backoff {
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+	return wait.Backoff{
+		Duration: pollInterval,
+		Factor:   1.5,
+		Jitter:   0.1,
+		Steps:    math.MaxInt32,
+		Cap:      maxPollInterval,
+	}
+}
+
+// poll checks the condition until it is done or fails, waiting for the next backoff step in between. The context
+// error is returned if the context is done first.
+func poll(ctx context.Context, backoff wait.Backoff, condition func() (bool, error)) error {
+	for {
+		if done, err := condition(); err != nil || done {
+			return err
+		}
+
+		timer := time.NewTimer(backoff.Step())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// CheckDependencies verifies that every package in addon.spec.pkgDeps is installed at a matching version, the version
+// "*" matches any installed version. The missing dependencies are returned as pkgName:pkgVersion.
+func (w *addonWorkflows) CheckDependencies(ctx context.Context) (bool, []string, error) {
+	if len(w.addon.Spec.PkgDeps) == 0 {
+		return true, nil, nil
+	}
+
+	if err := validatePackageDeps(w.addon.Spec.PkgDeps); err != nil {
+		return false, nil, err
+	}
+
+	addons := &addonmgrv1alpha1.AddonList{}
+	if err := w.List(ctx, addons); err != nil {
+		return false, nil, fmt.Errorf("unable to list addons. %v", err)
+	}
+
+	cache := installedVersions(addons)
+	if _, err := addon.ResolveDependencies(cache, w.addonVersion()); err != nil {
+		return false, nil, err
+	}
+
+	missing := addon.UnsatisfiedDependencies(cache, w.addon.Spec.PkgDeps)
+	return len(missing) == 0, missing, nil
+}
+
+// DependencyReport returns the status of every package in addon.spec.pkgDeps sorted by package name, without failing
+// on unsatisfied dependencies so it can be used to troubleshoot them
+func (w *addonWorkflows) DependencyReport(ctx context.Context) ([]DependencyStatus, error) {
+	if len(w.addon.Spec.PkgDeps) == 0 {
+		return nil, nil
+	}
+
+	addons := &addonmgrv1alpha1.AddonList{}
+	if err := w.List(ctx, addons); err != nil {
+		return nil, fmt.Errorf("unable to list addons. %v", err)
+	}
+
+	report := make([]DependencyStatus, 0, len(w.addon.Spec.PkgDeps))
+	for pkgName, pkgVersion := range w.addon.Spec.PkgDeps {
+		status := DependencyStatus{
+			PkgName:    strings.TrimSpace(pkgName),
+			Constraint: strings.TrimSpace(pkgVersion),
+		}
+
+		for _, a := range addons.Items {
+			if a.Spec.PkgName != status.PkgName || a.Status.Lifecycle.Installed != addonmgrv1alpha1.Succeeded {
+				continue
+			}
+			status.InstalledVersion = a.Spec.PkgVersion
+			if ok, _ := addon.SatisfiesConstraint(a.Spec.PkgVersion, status.Constraint); ok {
+				status.Satisfied = true
+				break
+			}
+		}
+
+		report = append(report, status)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].PkgName < report[j].PkgName
+	})
+
+	return report, nil
+}
+
+// TransitiveDependencies returns the sorted package names this addon depends on directly or through the package
+// dependencies of the installed addons. An ErrDependencyCycle is returned if a package depends on itself.
+func (w *addonWorkflows) TransitiveDependencies(ctx context.Context) ([]string, error) {
+	if len(w.addon.Spec.PkgDeps) == 0 {
+		return nil, nil
+	}
+
+	addons := &addonmgrv1alpha1.AddonList{}
+	if err := w.List(ctx, addons); err != nil {
+		return nil, fmt.Errorf("unable to list addons. %v", err)
+	}
+
+	return addon.ResolveDependencies(installedVersions(addons), w.addonVersion())
+}
+
+// DependentsPresent lists the other addons with this addon package in addon.spec.pkgDeps, the dependents are returned
+// sorted as namespace/name
+func (w *addonWorkflows) DependentsPresent(ctx context.Context) (bool, []string, error) {
+	addons := &addonmgrv1alpha1.AddonList{}
+	if err := w.List(ctx, addons); err != nil {
+		return false, nil, fmt.Errorf("unable to list addons. %v", err)
+	}
+
+	var dependents []string
+	for _, a := range addons.Items {
+		if a.GetNamespace() == w.addon.GetNamespace() && a.GetName() == w.addon.GetName() {
+			continue
+		}
+		for pkgName := range a.Spec.PkgDeps {
+			if strings.TrimSpace(pkgName) == w.addon.Spec.PkgName {
+				dependents = append(dependents, a.GetNamespace()+"/"+a.GetName())
+				break
+			}
+		}
+	}
+	sort.Strings(dependents)
+
+	return len(dependents) > 0, dependents, nil
+}
+
+// addonVersion returns the addon package as a cached version
+func (w *addonWorkflows) addonVersion() *addon.Version {
+	return &addon.Version{
+		Name:        w.addon.GetName(),
+		Namespace:   w.addon.GetNamespace(),
+		PackageSpec: w.addon.GetPackageSpec(),
+		PkgPhase:    w.addon.Status.Lifecycle.Installed,
+	}
+}
+
+// installedVersions caches the addon packages so their dependencies can be resolved
+func installedVersions(addons *addonmgrv1alpha1.AddonList) addon.VersionCacheClient {
+	cache := addon.NewAddonVersionCacheClient()
+	for _, a := range addons.Items {
+		cache.AddVersion(addon.Version{
+			Name:        a.GetName(),
+			Namespace:   a.GetNamespace(),
+			PackageSpec: a.GetPackageSpec(),
+			PkgPhase:    a.Status.Lifecycle.Installed,
+		})
+	}
+
+	return cache
+}
+
+// workflowPhase converts workflow.status.phase into an addon phase
+func workflowPhase(workflow *unstructured.Unstructured) addonmgrv1alpha1.ApplicationAssemblyPhase {
+	phase, _, _ := unstructured.NestedString(workflow.UnstructuredContent(), "status", "phase")
+	switch phase {
+	case "Running":
+		return addonmgrv1alpha1.Running
+	case "Succeeded":
+		return addonmgrv1alpha1.Succeeded
+	case "Failed", "Error":
+		return addonmgrv1alpha1.Failed
+	default:
+		return addonmgrv1alpha1.Pending
+	}
+}
+
+// RequeueAfter returns how long to wait before checking on an addon in the phase again, zero for terminal phases that
+// don't need to be polled
+func RequeueAfter(phase addonmgrv1alpha1.ApplicationAssemblyPhase) time.Duration {
+	switch phase {
+	case addonmgrv1alpha1.Pending, addonmgrv1alpha1.Running, addonmgrv1alpha1.Deleting:
+		return requeueInterval
+	default:
+		return 0
+	}
+}
+
+// isWorkflowCompleted checks if the workflow status has reached a terminal phase
+func isWorkflowCompleted(workflow *unstructured.Unstructured) bool {
+	phase, _, _ := unstructured.NestedString(workflow.UnstructuredContent(), "status", "phase")
+	return phase == "Succeeded" || phase == "Failed" || phase == "Error"
+}
+
+func (w *addonWorkflows) findWorkflowByName(ctx context.Context, name types.NamespacedName) (*unstructured.Unstructured, error) {
+	// Looked up through the dynamic client workflows are created with, a cached read could miss a workflow
+	// submitted by the previous reconcile and submit it again
+	found, err := w.dynClient.Resource(w.gvr).Namespace(name.Namespace).Get(name.Name, metav1.GetOptions{})
+	if err != nil && apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+// submit creates the rendered workflow or reports on the existing workflow submitted before for it
+func (w *addonWorkflows) submit(ctx context.Context, wp, existing *unstructured.Unstructured) (addonmgrv1alpha1.ApplicationAssemblyPhase, string, error) {
+	// Applied workflows are patched into place whether they exist or not
+	if w.apply && wp.GetName() != "" {
+		return w.create(ctx, wp)
+	}
+
+	wfv1 := existing
+
+	// Check if the same Addon spec was submitted and completed previously
+	if wfv1 != nil {
+		deleted, err := w.deleteCollisionWorkflows(ctx, wfv1)
+		if err != nil {
+			return addonmgrv1alpha1.Failed, wp.GetName(), err
+		}
+		if deleted {
+			return addonmgrv1alpha1.Pending, wp.GetName(), nil
+		}
+	}
+
+	// A named workflow that renders differently than before is replaced, workflows submitted before they carried a
+	// checksum are kept
+	if wfv1 != nil {
+		checksum, ok := wfv1.GetAnnotations()[WorkflowChecksumAnnotation]
+		if ok && checksum != wp.GetAnnotations()[WorkflowChecksumAnnotation] {
+			if err := w.Delete(ctx, wfv1.GetName()); err != nil {
+				return addonmgrv1alpha1.Failed, wp.GetName(), err
+			}
+			if err := w.waitForDeletion(ctx, wfv1.GetName(), wfv1.GetUID()); err != nil {
+				return addonmgrv1alpha1.Failed, wp.GetName(), err
+			}
+			wfv1 = nil
+		}
+	}
+
+	if wfv1 == nil {
+		return w.create(ctx, wp)
+	}
+
+	// validate workflow status
+	var phase = addonmgrv1alpha1.Pending
+	status, ok := wfv1.UnstructuredContent()["status"].(map[string]interface{})
+	if ok && status["phase"] == "Succeeded" {
+		phase = addonmgrv1alpha1.Succeeded
+	} else if ok && status["phase"] == "Failed" {
+		phase = addonmgrv1alpha1.Failed
+	}
+
+	return phase, wfv1.GetName(), nil
+}
+
+// create submits the rendered workflow, named workflows are applied when server-side apply is enabled
+func (w *addonWorkflows) create(ctx context.Context, wp *unstructured.Unstructured) (addonmgrv1alpha1.ApplicationAssemblyPhase, string, error) {
+	wfv1 := &unstructured.Unstructured{}
+
+	// Convert proxy to workflow object
+	err := w.scheme.Convert(wp, wfv1, 0)
+	if err != nil {
+		return addonmgrv1alpha1.Failed, wp.GetName(), err
+	}
+	wfv1.SetGroupVersionKind(w.gvr.GroupVersion().WithKind("Workflow"))
+	wfv1.SetNamespace(wp.GetNamespace())
+	wfv1.SetName(wp.GetName())
+	wfv1.SetGenerateName(wp.GetGenerateName())
+	// Set the owner references for workflow so it's garbage collected with the addon, owners can't be in
+	// another namespace so workflows submitted elsewhere are only tracked by their labels
+	if wfv1.GetNamespace() == w.addon.GetNamespace() {
+		if w.addon.GetUID() == "" {
+			return addonmgrv1alpha1.Failed, wp.GetName(), fmt.Errorf("addon %s/%s has no uid to set as workflow owner", w.addon.GetNamespace(), w.addon.GetName())
+		}
+		if err := controllerutil.SetControllerReference(w.addon, wfv1, w.scheme); err != nil {
+			return addonmgrv1alpha1.Failed, wp.GetName(), err
+		}
+	}
+
+	w.log.Info("submitting workflow", "workflow", wfv1.GetName(), "generateName", wfv1.GetGenerateName(), "pkgType", w.addon.Spec.PkgType, "namespace", wfv1.GetNamespace())
+
+	start := time.Now()
+	var created *unstructured.Unstructured
+	if w.apply && wfv1.GetName() != "" {
+		created, err = w.applyWorkflow(wfv1)
+	} else {
+		created, err = w.dynClient.Resource(w.gvr).Namespace(wfv1.GetNamespace()).Create(wfv1, metav1.CreateOptions{FieldManager: w.fieldManager})
+	}
+	workflowSubmitDuration.Observe(time.Since(start).Seconds())
+	if err != nil && apierrors.IsAlreadyExists(err) {
+		// Another reconcile submitted the workflow first, report on the existing one
+		existing, err := w.dynClient.Resource(w.gvr).Namespace(wfv1.GetNamespace()).Get(wfv1.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return addonmgrv1alpha1.Failed, wp.GetName(), fmt.Errorf("could not find workflow %s/%s. %v", wfv1.GetNamespace(), wfv1.GetName(), err)
+		}
+		w.recorder.Event(w.addon, "Normal", "WorkflowExists", fmt.Sprintf("Workflow %s for addon %s/%s was already submitted", existing.GetName(), w.addon.Namespace, w.addon.Name))
+		return workflowPhase(existing), existing.GetName(), nil
+	}
+	if err != nil {
+		// A generated name is only known once the workflow is created
+		workflow := wfv1.GetName()
+		if workflow == "" {
+			workflow = fmt.Sprintf("with generateName %s", wfv1.GetGenerateName())
+		}
+		w.recorder.Event(w.addon, "Warning", "WorkflowSubmitFailed", fmt.Sprintf("Failed to submit workflow %s for addon %s/%s. %v", workflow, w.addon.Namespace, w.addon.Name, err))
+		return addonmgrv1alpha1.Failed, wp.GetName(), err
+	}
+	w.log.V(1).Info("submitted workflow", "workflow", created.GetName(), "namespace", created.GetNamespace())
+	// Record an event for created workflow
+	w.recorder.Event(w.addon, "Normal", "WorkflowSubmitted", fmt.Sprintf("Submitted workflow %s for addon %s/%s", created.GetName(), w.addon.Namespace, w.addon.Name))
+	// Argo hasn't picked up a workflow without status, only a newly submitted one waits on its approval
+	if suspended, _, _ := unstructured.NestedBool(created.Object, "spec", "suspend"); suspended && created.Object["status"] == nil {
+		w.recorder.Event(w.addon, "Normal", "ApprovalRequired", fmt.Sprintf("Workflow %s/%s is suspended until it is resumed", created.GetNamespace(), created.GetName()))
+	}
+
+	// An applied workflow may have been submitted and started before
+	phase := workflowPhase(created)
+
+	w.lastMutex.Lock()
+	w.lastWorkflows[types.NamespacedName{Namespace: w.addon.GetNamespace(), Name: w.addon.GetName()}] = lastWorkflow{name: created.GetName(), phase: phase}
+	w.lastMutex.Unlock()
+
+	return phase, created.GetName(), nil
+}
+
+func (w *addonWorkflows) parse(wt *addonmgrv1alpha1.WorkflowType, wf *unstructured.Unstructured, name string) error {
+	var data map[string]interface{}
+
+	switch {
+	case wt.Template != "" && wt.TemplateRef != "":
+		return errors.New("only one of template or templateRef can be set")
+	case wt.TemplateRef != "":
+		ref, err := w.workflowTemplateRef(wt.TemplateRef)
+		if err != nil {
+			return err
+		}
+		data = ref
+	default:
+		// Load workflow spec into data obj
+		if err := yaml.Unmarshal([]byte(wt.Template), &data); err != nil {
+			return fmt.Errorf("invalid workflow yaml spec passed. %v", err)
+		}
+
+		if err := validateWorkflowTemplate(data); err != nil {
+			return err
+		}
+	}
+
+	wf.SetGroupVersionKind(w.gvr.GroupVersion().WithKind("Workflow"))
+
+	wf.SetNamespace(w.workflowNamespace())
+	if name != "" {
+		wf.SetName(name)
+	} else {
+		// Let the API server generate a unique name that can be traced back to the addon package
+		wf.SetGenerateName(w.workflowGenerateName(wt))
+	}
+	// Keep labels and annotations given in the template
+	if labels, found, _ := unstructured.NestedStringMap(data, "metadata", "labels"); found {
+		wf.SetLabels(labels)
+	}
+	if annotations, found, _ := unstructured.NestedStringMap(data, "metadata", "annotations"); found {
+		wf.SetAnnotations(annotations)
+	}
+	content := wf.UnstructuredContent()
+
+	spec := data["spec"].(map[string]interface{})
+
+	content["spec"] = spec
+	wf.SetUnstructuredContent(content)
+
+	return nil
+}
+
+// workflowGenerateName derives the generateName from the name prefix and package name, names that are too long are
+// truncated and suffixed with a hash of the full name to keep them unique
+func (w *addonWorkflows) workflowGenerateName(wt *addonmgrv1alpha1.WorkflowType) string {
+	name := sanitizeName(w.addon.Spec.PkgName)
+	if name == "" {
+		name = sanitizeName(w.addon.GetName())
+	}
+	if wt.NamePrefix != "" {
+		name = fmt.Sprintf("%s-%s", wt.NamePrefix, name)
+	}
+
+	// Leave room for the trailing dash
+	if len(name) >= maxGenerateNameLength {
+		hash := fmt.Sprintf("%08x", adler32.Checksum([]byte(name)))
+		name = strings.TrimRight(name[:maxGenerateNameLength-len(hash)-2], "-") + "-" + hash
+	}
+
+	return name + "-"
+}
+
+// sanitizeName lowercases the name and replaces characters not allowed in a DNS-1123 label with dashes
+func sanitizeName(name string) string {
+	name = invalidNameCharsRegexp.ReplaceAllString(strings.ToLower(name), "-")
+	return strings.Trim(name, "-")
+}
+
+// workflowTemplateRef builds a workflow that runs the referenced WorkflowTemplate, argo only resolves templates in
+// the namespace of the workflow
+func (w *addonWorkflows) workflowTemplateRef(ref string) (map[string]interface{}, error) {
+	name := ref
+	if parts := strings.Split(ref, "/"); len(parts) == 2 {
+		if parts[0] != w.workflowNamespace() {
+			return nil, fmt.Errorf("invalid templateRef %q, WorkflowTemplate must be in namespace %s", ref, w.workflowNamespace())
+		}
+		name = parts[1]
+	}
+
+	if name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid templateRef %q, expected name or namespace/name", ref)
+	}
+
+	return map[string]interface{}{
+		"apiVersion": w.gvr.GroupVersion().String(),
+		"kind":       "Workflow",
+		"spec": map[string]interface{}{
+			"workflowTemplateRef": map[string]interface{}{
+				"name": name,
+			},
+		},
+	}, nil
+}
+
+// validateWorkflowTemplate makes sure the template is a workflow that argo will be able to run. The template types are
+// left to argo, templates that run without a pod like http or suspend templates are as valid as container templates.
+func validateWorkflowTemplate(data map[string]interface{}) error {
+	if kind, _, _ := unstructured.NestedString(data, "kind"); kind != "Workflow" {
+		return fmt.Errorf("invalid workflow, kind %q is not Workflow", kind)
+	}
+
+	if _, ok := data["spec"].(map[string]interface{}); !ok {
+		return errors.New("invalid workflow, missing spec")
+	}
+
+	if entrypoint, _, _ := unstructured.NestedString(data, "spec", "entrypoint"); entrypoint == "" {
+		return errors.New("invalid workflow, missing spec.entrypoint")
+	}
+
+	return nil
+}
+
+// EntrypointOf returns the entrypoint of the workflow type template, it errors if the template is invalid or the
+// entrypoint isn't one of its templates
+func EntrypointOf(wt *addonmgrv1alpha1.WorkflowType) (string, error) {
+	data := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(wt.Template), &data); err != nil {
+		return "", fmt.Errorf("invalid workflow yaml spec passed. %v", err)
+	}
+
+	if err := validateWorkflowTemplate(data); err != nil {
+		return "", err
+	}
+
+	entrypoint, _, _ := unstructured.NestedString(data, "spec", "entrypoint")
+	templates, _, _ := unstructured.NestedSlice(data, "spec", "templates")
+	for _, t := range templates {
+		if t, ok := t.(map[string]interface{}); ok && t["name"] == entrypoint {
+			return entrypoint, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid workflow, entrypoint %q is not defined in spec.templates", entrypoint)
+}
+
+// Sets workflow.spec.ttlStrategy.secondsAfterCompletion from the workflow type, or the default if the template has none.
+// A template's deprecated ttlSecondsAfterFinished counts as its ttl, it is dropped when the workflow type sets one.
+func (w *addonWorkflows) configureWorkflowTTL(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	if wt.TTLSecondsAfterCompletion == nil {
+		if _, found, _ := unstructured.NestedFieldNoCopy(wf.UnstructuredContent(), "spec", "ttlStrategy", "secondsAfterCompletion"); found {
+			return nil
+		}
+		if _, found, _ := unstructured.NestedFieldNoCopy(wf.UnstructuredContent(), "spec", "ttlSecondsAfterFinished"); found {
+			return nil
+		}
+	}
+	unstructured.RemoveNestedField(wf.UnstructuredContent(), "spec", "ttlSecondsAfterFinished")
+
+	ttl := w.defaultTTL
+	if wt.TTLSecondsAfterCompletion != nil {
+		ttl = *wt.TTLSecondsAfterCompletion
+	}
+
+	return unstructured.SetNestedField(wf.UnstructuredContent(), int64(ttl), "spec", "ttlStrategy", "secondsAfterCompletion")
+}
+
+// Sets workflow.spec.activeDeadlineSeconds from the workflow type, or the default if the template has none
+func (w *addonWorkflows) configureActiveDeadline(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	if wt.ActiveDeadlineSeconds == nil {
+		if _, found, _ := unstructured.NestedFieldNoCopy(wf.UnstructuredContent(), "spec", "activeDeadlineSeconds"); found {
+			return nil
+		}
+	}
+
+	deadline := w.activeDeadline
+	if wt.ActiveDeadlineSeconds != nil {
+		deadline = *wt.ActiveDeadlineSeconds
+	}
+
+	return unstructured.SetNestedField(wf.UnstructuredContent(), deadline, "spec", "activeDeadlineSeconds")
+}
+
+// Copies the addon labels listed by its propagate-labels annotation along with the package name and version onto the
+// workflow, template labels take precedence. The lifecycle step label is always set, workflows of a step are looked up
+// by it.
+func (w *addonWorkflows) configureWorkflowMetadata(wf *unstructured.Unstructured, step addonmgrv1alpha1.LifecycleStep) {
+	labels := make(map[string]string)
+	for _, k := range strings.Split(w.addon.GetAnnotations()[PropagateLabelsAnnotation], ",") {
+		k = strings.TrimSpace(k)
+		if v, ok := w.addon.GetLabels()[k]; ok {
+			labels[k] = v
+		}
+	}
+	labels["app.kubernetes.io/name"] = w.addon.Spec.PkgName
+	labels["app.kubernetes.io/managed-by"] = common.AddonGVR().Group
+	labels[WorkflowAddonLabel] = w.addon.GetName()
+	labels[WorkflowVersionLabel] = w.addon.Spec.PkgVersion
+	for k, v := range wf.GetLabels() {
+		labels[k] = v
+	}
+	if step != "" {
+		labels[WorkflowStepLabel] = string(step)
+	}
+	wf.SetLabels(labels)
+
+	annotations := wf.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[WorkflowSourceAnnotation] = fmt.Sprintf("%s/%s", w.addon.GetNamespace(), w.addon.GetName())
+	wf.SetAnnotations(annotations)
+}
+
+// Sets workflow.spec.serviceAccountName to the workflow type role, a service account given in the template takes precedence
+func (w *addonWorkflows) configureServiceAccount(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	if wt.Role == "" {
+		return nil
+	}
+
+	if sa, _, _ := unstructured.NestedString(wf.UnstructuredContent(), "spec", "serviceAccountName"); sa != "" {
+		return nil
+	}
+
+	return unstructured.SetNestedField(wf.UnstructuredContent(), wt.Role, "spec", "serviceAccountName")
+}
+
+// Appends the workflow type image pull secrets to workflow.spec.imagePullSecrets, skipping empty and duplicate names
+func (w *addonWorkflows) configureImagePullSecrets(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	if len(wt.ImagePullSecrets) == 0 {
+		return nil
+	}
+
+	secrets, _, err := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "imagePullSecrets")
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]bool)
+	for _, secret := range secrets {
+		if secret, ok := secret.(map[string]interface{}); ok {
+			if name, ok := secret["name"].(string); ok {
+				names[name] = true
+			}
+		}
+	}
+
+	for _, name := range wt.ImagePullSecrets {
+		name = strings.TrimSpace(name)
+		if name == "" || names[name] {
+			continue
+		}
+		names[name] = true
+		secrets = append(secrets, map[string]interface{}{"name": name})
+	}
+
+	return unstructured.SetNestedSlice(wf.UnstructuredContent(), secrets, "spec", "imagePullSecrets")
+}
+
+// Rewrites the container, script, init container and sidecar images of the workflow templates to the registry mirror
+func (w *addonWorkflows) configureRegistryMirror(wf *unstructured.Unstructured) error {
+	if w.registryMirror == "" {
+		return nil
+	}
+
+	return updateTemplates(wf, func(template map[string]interface{}) error {
+		containers := []interface{}{template["container"], template["script"]}
+		for _, key := range []string{"initContainers", "sidecars"} {
+			if list, ok := template[key].([]interface{}); ok {
+				containers = append(containers, list...)
+			}
+		}
+
+		for _, container := range containers {
+			container, ok := container.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if image, ok := container["image"].(string); ok && image != "" {
+				container["image"] = mirrorImage(w.registryMirror, image)
+			}
+		}
+
+		return nil
+	})
+}
+
+// mirrorImage prepends the mirror host to the image, keeping the registry host in the path so images of different
+// registries don't collide on the mirror
+func mirrorImage(mirror, image string) string {
+	if strings.HasPrefix(image, mirror+"/") {
+		return image
+	}
+
+	return mirror + "/" + image
+}
+
+// Sets workflow.spec.nodeSelector, workflow.spec.tolerations and workflow.spec.affinity from the workflow type
+func (w *addonWorkflows) configureNodeScheduling(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	if len(wt.NodeSelector) > 0 {
+		err := unstructured.SetNestedStringMap(wf.UnstructuredContent(), wt.NodeSelector, "spec", "nodeSelector")
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(wt.Tolerations) > 0 {
+		tolerations := make([]interface{}, 0, len(wt.Tolerations))
+		for i := range wt.Tolerations {
+			toleration, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&wt.Tolerations[i])
+			if err != nil {
+				return err
+			}
+			tolerations = append(tolerations, toleration)
+		}
+
+		err := unstructured.SetNestedSlice(wf.UnstructuredContent(), tolerations, "spec", "tolerations")
+		if err != nil {
+			return err
+		}
+	}
+
+	if wt.Affinity != nil {
+		affinity, err := runtime.DefaultUnstructuredConverter.ToUnstructured(wt.Affinity)
+		if err != nil {
+			return err
+		}
+
+		err = unstructured.SetNestedMap(wf.UnstructuredContent(), affinity, "spec", "affinity")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mergePodSpecPatch adds the fields to workflow.spec.podSpecPatch, fields already in the template patch take precedence
+func mergePodSpecPatch(wf *unstructured.Unstructured, fields map[string]interface{}) error {
+	patch := make(map[string]interface{})
+	if existing, _, _ := unstructured.NestedString(wf.UnstructuredContent(), "spec", "podSpecPatch"); existing != "" {
+		if err := yaml.Unmarshal([]byte(existing), &patch); err != nil {
+			return fmt.Errorf("invalid workflow podSpecPatch. %v", err)
+		}
+	}
+
+	for k, v := range fields {
+		if _, ok := patch[k]; !ok {
+			patch[k] = v
+		}
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	return unstructured.SetNestedField(wf.UnstructuredContent(), string(data), "spec", "podSpecPatch")
+}
+
+// Sets workflow.spec.podGC.strategy from the workflow type
+func (w *addonWorkflows) configurePodGC(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	switch wt.PodGCStrategy {
+	case "":
+		return nil
+	case "OnPodCompletion", "OnPodSuccess", "OnWorkflowCompletion", "OnWorkflowSuccess":
+	default:
+		return fmt.Errorf("invalid podGCStrategy %q", wt.PodGCStrategy)
+	}
+
+	return unstructured.SetNestedField(wf.UnstructuredContent(), wt.PodGCStrategy, "spec", "podGC", "strategy")
+}
+
+// Adds the workflow type pod annotations to workflow.spec.podMetadata.annotations, template annotations take precedence
+func (w *addonWorkflows) configurePodAnnotations(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	if len(wt.PodAnnotations) == 0 {
+		return nil
+	}
+
+	annotations, _, err := unstructured.NestedStringMap(wf.UnstructuredContent(), "spec", "podMetadata", "annotations")
+	if err != nil {
+		return err
+	}
+	if annotations == nil {
+		annotations = make(map[string]string, len(wt.PodAnnotations))
+	}
+
+	for k, v := range wt.PodAnnotations {
+		if _, ok := annotations[k]; !ok {
+			annotations[k] = v
+		}
+	}
+
+	return unstructured.SetNestedStringMap(wf.UnstructuredContent(), annotations, "spec", "podMetadata", "annotations")
+}
+
+// Sets workflow.spec.securityContext from the workflow type unless the template sets one
+func (w *addonWorkflows) configureSecurityContext(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	if wt.SecurityContext == nil {
+		return nil
+	}
+
+	if _, ok, _ := unstructured.NestedFieldNoCopy(wf.UnstructuredContent(), "spec", "securityContext"); ok {
+		return nil
+	}
+
+	securityContext, err := runtime.DefaultUnstructuredConverter.ToUnstructured(wt.SecurityContext)
+	if err != nil {
+		return err
+	}
+
+	return unstructured.SetNestedMap(wf.UnstructuredContent(), securityContext, "spec", "securityContext")
+}
+
+// Adds the workflow type host aliases to workflow.spec.hostAliases, host aliases of the template for the same IP take
+// precedence
+func (w *addonWorkflows) configureHostAliases(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	if len(wt.HostAliases) == 0 {
+		return nil
+	}
+
+	existing, _, err := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "hostAliases")
+	if err != nil {
+		return fmt.Errorf("invalid workflow hostAliases. %v", err)
+	}
+	ips := make(map[string]bool)
+	for _, hostAlias := range existing {
+		if hostAlias, ok := hostAlias.(map[string]interface{}); ok {
+			if ip, ok := hostAlias["ip"].(string); ok {
+				ips[ip] = true
+			}
+		}
+	}
+
+	for i := range wt.HostAliases {
+		if ips[wt.HostAliases[i].IP] {
+			continue
+		}
+		hostAlias, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&wt.HostAliases[i])
+		if err != nil {
+			return err
+		}
+		existing = append(existing, hostAlias)
+	}
+
+	return unstructured.SetNestedSlice(wf.UnstructuredContent(), existing, "spec", "hostAliases")
+}
+
+// Adds the workflow type template defaults to workflow.spec.templateDefaults, fields already set by the template take
+// precedence
+func (w *addonWorkflows) configureTemplateDefaults(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	if wt.TemplateDefaults == "" {
+		return nil
+	}
+
+	defaults := make(map[string]interface{})
+	if err := yaml.Unmarshal([]byte(wt.TemplateDefaults), &defaults); err != nil {
+		return fmt.Errorf("invalid templateDefaults. %v", err)
+	}
+
+	existing, _, err := unstructured.NestedMap(wf.UnstructuredContent(), "spec", "templateDefaults")
+	if err != nil {
+		return fmt.Errorf("invalid workflow templateDefaults. %v", err)
+	}
+	for k, v := range existing {
+		defaults[k] = v
+	}
+
+	return unstructured.SetNestedMap(wf.UnstructuredContent(), defaults, "spec", "templateDefaults")
+}
+
+// Sets workflow.spec.onExit from the workflow type, the exit handler must be one of the workflow templates
+func (w *addonWorkflows) configureOnExit(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	if wt.OnExitTemplate == "" {
+		return nil
+	}
+
+	// Templates of a referenced WorkflowTemplate are resolved by argo
+	if _, found, _ := unstructured.NestedFieldNoCopy(wf.UnstructuredContent(), "spec", "workflowTemplateRef"); !found {
+		templates, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "templates")
+
+		var found bool
+		for _, template := range templates {
+			if template, ok := template.(map[string]interface{}); ok && template["name"] == wt.OnExitTemplate {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("invalid onExitTemplate %q, no such template in the workflow", wt.OnExitTemplate)
+		}
+	}
+
+	return unstructured.SetNestedField(wf.UnstructuredContent(), wt.OnExitTemplate, "spec", "onExit")
+}
+
+// Sets workflow.spec.synchronization from the workflow type unless the template sets one
+func (w *addonWorkflows) configureSynchronization(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	cfg := wt.Synchronization
+	if cfg == nil {
+		return nil
 	}
-}
 
-func (w *workflowLifecycle) Install(ctx context.Context, wt *addonmgrv1alpha1.WorkflowType, name string) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
-	wp := &unstructured.Unstructured{}
-	err := w.parse(wt, wp, name)
-	if err != nil {
-		return addonmgrv1alpha1.Failed, fmt.Errorf("invalid workflow. %v", err)
+	var synchronization map[string]interface{}
+	switch {
+	case cfg.Semaphore != nil && cfg.Mutex != "", cfg.Semaphore == nil && cfg.Mutex == "":
+		return errors.New("invalid synchronization, set either semaphore or mutex")
+	case cfg.Semaphore != nil:
+		if cfg.Semaphore.Name == "" || cfg.Semaphore.Key == "" {
+			return errors.New("invalid synchronization, semaphore requires a config map name and key")
+		}
+		synchronization = map[string]interface{}{
+			"semaphore": map[string]interface{}{
+				"configMapKeyRef": map[string]interface{}{"name": cfg.Semaphore.Name, "key": cfg.Semaphore.Key},
+			},
+		}
+	default:
+		synchronization = map[string]interface{}{"mutex": map[string]interface{}{"name": cfg.Mutex}}
 	}
 
-	if !w.configureGlobalWFParameters(w.addon, wp) {
-		return addonmgrv1alpha1.Failed, errors.New("invalid workflow parameter")
+	if _, ok, _ := unstructured.NestedFieldNoCopy(wf.UnstructuredContent(), "spec", "synchronization"); ok {
+		return nil
 	}
 
-	err = w.configureWorkflowArtifacts(wp, wt)
-	if err != nil {
-		return addonmgrv1alpha1.Failed, err
+	return unstructured.SetNestedMap(wf.UnstructuredContent(), synchronization, "spec", "synchronization")
+}
+
+// Sets the image pull policy of the container and script templates of the workflow, overriding the template
+func (w *addonWorkflows) configureImagePullPolicy(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	switch corev1.PullPolicy(wt.ImagePullPolicy) {
+	case "":
+		return nil
+	case corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever:
+	default:
+		return fmt.Errorf("invalid imagePullPolicy %q", wt.ImagePullPolicy)
 	}
 
-	return w.submit(ctx, wp)
+	return updateTemplates(wf, func(template map[string]interface{}) error {
+		for _, key := range []string{"container", "script"} {
+			if container, ok := template[key].(map[string]interface{}); ok {
+				container["imagePullPolicy"] = wt.ImagePullPolicy
+			}
+		}
+		return nil
+	})
 }
 
-// Appends addon.spec.params to workflow.spec.arguments.parameters
-func (w *workflowLifecycle) configureGlobalWFParameters(addon *addonmgrv1alpha1.Addon, wf *unstructured.Unstructured) bool {
-	// get workflow argument parameters
-	spec, _ := wf.UnstructuredContent()["spec"].(map[string]interface{})
-	if spec["arguments"] == nil {
-		spec["arguments"] = make(map[string]interface{})
+// Sets the duration of the suspend templates of the workflow that don't have one. A workflow without suspend templates
+// gets a suspend gate step ahead of its entrypoint, the entrypoint inputs are passed on from the workflow arguments.
+func (w *addonWorkflows) configureSuspendDuration(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	if wt.SuspendDuration == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(wt.SuspendDuration); err != nil || d <= 0 {
+		return fmt.Errorf("invalid suspendDuration %q", wt.SuspendDuration)
 	}
 
-	arguments := spec["arguments"].(map[string]interface{})
-	if arguments["parameters"] == nil {
-		arguments["parameters"] = make([]interface{}, 0)
+	templates, _, err := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "templates")
+	if err != nil {
+		return err
 	}
 
-	wfParams := arguments["parameters"].([]interface{})
-	if wfParams == nil {
-		arguments["parameters"] = make([]interface{}, 0)
+	var gated bool
+	for _, template := range templates {
+		template, ok := template.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if suspend, ok := template["suspend"].(map[string]interface{}); ok {
+			if _, ok := suspend["duration"]; !ok {
+				suspend["duration"] = wt.SuspendDuration
+			}
+			gated = true
+		}
 	}
 
-	// get addon params
-	namespaceParam := addon.Spec.Params.Namespace
-	contextParams := addon.Spec.Params.Context
-	dataParams := addon.Spec.Params.Data
+	if !gated {
+		entrypoint, _, _ := unstructured.NestedString(wf.UnstructuredContent(), "spec", "entrypoint")
 
-	namespaceMap := make(map[string]interface{})
-	namespaceMap["name"] = "namespace"
-	namespaceMap["value"] = namespaceParam
+		args, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "arguments", "parameters")
+		global := make(map[string]bool)
+		for _, arg := range args {
+			if arg, ok := arg.(map[string]interface{}); ok {
+				if name, ok := arg["name"].(string); ok {
+					global[name] = true
+				}
+			}
+		}
 
-	wfParams = append(wfParams, namespaceMap)
+		var params []interface{}
+		for _, template := range templates {
+			template, ok := template.(map[string]interface{})
+			if !ok || template["name"] != entrypoint {
+				continue
+			}
+			inputs, _, _ := unstructured.NestedSlice(template, "inputs", "parameters")
+			for _, input := range inputs {
+				if input, ok := input.(map[string]interface{}); ok {
+					if name, ok := input["name"].(string); ok && global[name] {
+						params = append(params, map[string]interface{}{
+							"name":  name,
+							"value": fmt.Sprintf("{{workflow.parameters.%s}}", name),
+						})
+					}
+				}
+			}
+		}
 
-	// Copy general Context string params to global workflow variables (clusterName and clusterRegion currently)
-	cp := reflect.ValueOf(contextParams)
-	for i := 0; i < cp.Type().NumField(); i++ {
-		contextMap := make(map[string]interface{})
-		kind := cp.Field(i).Kind()
-		if kind == reflect.String {
-			fieldName := cp.Type().Field(i).Name
-			tag := cp.Type().Field(i).Tag
-			jsonTag := strings.Split(tag.Get("json"), ",")[0]
-			contextMap["name"] = jsonTag
-			contextMap["value"] = cp.FieldByName(fieldName).String()
-			wfParams = append(wfParams, contextMap)
+		step := map[string]interface{}{"name": "install", "template": entrypoint}
+		if len(params) > 0 {
+			step["arguments"] = map[string]interface{}{"parameters": params}
+		}
+		templates = append(templates,
+			map[string]interface{}{
+				"name": suspendEntrypointTemplate,
+				"steps": []interface{}{
+					[]interface{}{map[string]interface{}{"name": "approve", "template": suspendGateTemplate}},
+					[]interface{}{step},
+				},
+			},
+			map[string]interface{}{
+				"name":    suspendGateTemplate,
+				"suspend": map[string]interface{}{"duration": wt.SuspendDuration},
+			},
+		)
+
+		err = unstructured.SetNestedField(wf.UnstructuredContent(), suspendEntrypointTemplate, "spec", "entrypoint")
+		if err != nil {
+			return err
 		}
 	}
 
-	// Copy AdditionalConfigs from Context to global workflow variables
-	for name, value := range contextParams.AdditionalConfigs {
-		addParam := make(map[string]interface{})
-		addParam["name"] = name
-		addParam["value"] = string(value)
-		wfParams = append(wfParams, addParam)
+	return unstructured.SetNestedSlice(wf.UnstructuredContent(), templates, "spec", "templates")
+}
+
+// Sets workflow.spec.parallelism from the workflow type
+func (w *addonWorkflows) configureParallelism(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	if wt.Parallelism == nil {
+		return nil
 	}
 
-	// Copy stringParams to global workflow variables
-	for name, value := range dataParams {
-		addParam := make(map[string]interface{})
-		addParam["name"] = name
-		addParam["value"] = string(value)
-		wfParams = append(wfParams, addParam)
+	return unstructured.SetNestedField(wf.UnstructuredContent(), *wt.Parallelism, "spec", "parallelism")
+}
+
+// Sets resources on the container and script templates of the workflow, templates with resources are left as is
+func (w *addonWorkflows) configurePodResources(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	if wt.PodResources == nil || (len(wt.PodResources.Requests) == 0 && len(wt.PodResources.Limits) == 0) {
+		return nil
 	}
 
-	err := unstructured.SetNestedSlice(wf.UnstructuredContent(), wfParams, "spec", "arguments", "parameters")
+	resources, err := runtime.DefaultUnstructuredConverter.ToUnstructured(wt.PodResources)
 	if err != nil {
-		return false
+		return err
 	}
 
-	return true
+	return updateTemplates(wf, func(template map[string]interface{}) error {
+		for _, key := range []string{"container", "script"} {
+			container, ok := template[key].(map[string]interface{})
+			if !ok || container["resources"] != nil {
+				continue
+			}
+			container["resources"] = runtime.DeepCopyJSONValue(resources)
+		}
+		return nil
+	})
 }
 
-func (w *workflowLifecycle) Delete(name string) error {
-	err := w.dynClient.Resource(common.WorkflowGVR()).Namespace(w.addon.Namespace).Delete(name, &metav1.DeleteOptions{})
-	if err != nil {
-		return err
+// Adds the workflow type env to the container and script templates of the workflow, template variables take precedence
+func (w *addonWorkflows) configureEnv(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	if len(wt.Env) == 0 {
+		return nil
 	}
-	return nil
-}
 
-func (w *workflowLifecycle) findWorkflowByName(ctx context.Context, name types.NamespacedName) (*unstructured.Unstructured, error) {
-	found := &unstructured.Unstructured{}
-	found.SetGroupVersionKind(schema.GroupVersionKind{
-		Kind:    "Workflow",
-		Group:   "argoproj.io",
-		Version: "v1alpha1",
+	env := make([]interface{}, 0, len(wt.Env))
+	for i := range wt.Env {
+		envVar, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&wt.Env[i])
+		if err != nil {
+			return err
+		}
+		env = append(env, envVar)
+	}
+
+	return updateTemplates(wf, func(template map[string]interface{}) error {
+		for _, key := range []string{"container", "script"} {
+			container, ok := template[key].(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			existing, _ := container["env"].([]interface{})
+			names := make(map[string]bool)
+			for _, envVar := range existing {
+				if envVar, ok := envVar.(map[string]interface{}); ok {
+					if name, ok := envVar["name"].(string); ok {
+						names[name] = true
+					}
+				}
+			}
+			for _, envVar := range env {
+				if !names[envVar.(map[string]interface{})["name"].(string)] {
+					existing = append(existing, runtime.DeepCopyJSONValue(envVar))
+				}
+			}
+			container["env"] = existing
+		}
+		return nil
 	})
-	err := w.Get(ctx, name, found)
-	if err != nil && apierrors.IsNotFound(err) {
-		return nil, nil
-	} else if err != nil {
-		return nil, err
+}
+
+// Adds the workflow type init containers to the container and script templates, init containers already in the template
+// take precedence
+func (w *addonWorkflows) configureInitContainers(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	if len(wt.InitContainers) == 0 {
+		return nil
 	}
 
-	return found, nil
+	initContainers := make([]interface{}, 0, len(wt.InitContainers))
+	for i := range wt.InitContainers {
+		initContainer, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&wt.InitContainers[i])
+		if err != nil {
+			return err
+		}
+		initContainers = append(initContainers, initContainer)
+	}
+
+	return updateTemplates(wf, func(template map[string]interface{}) error {
+		if template["container"] == nil && template["script"] == nil {
+			return nil
+		}
+
+		existing, _ := template["initContainers"].([]interface{})
+		names := make(map[string]bool)
+		for _, initContainer := range existing {
+			if initContainer, ok := initContainer.(map[string]interface{}); ok {
+				if name, ok := initContainer["name"].(string); ok {
+					names[name] = true
+				}
+			}
+		}
+		for _, initContainer := range initContainers {
+			if !names[initContainer.(map[string]interface{})["name"].(string)] {
+				existing = append(existing, runtime.DeepCopyJSONValue(initContainer))
+			}
+		}
+		template["initContainers"] = existing
+		return nil
+	})
 }
 
-func (w *workflowLifecycle) submit(ctx context.Context, wp *unstructured.Unstructured) (addonmgrv1alpha1.ApplicationAssemblyPhase, error) {
-	var wfv1 *unstructured.Unstructured
+// Adds a secret volume to the workflow for each addon secret and mounts it read-only into the container and script
+// templates, templates which already mount the volume name or path are left as is
+func (w *addonWorkflows) configureSecrets(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	if !wt.MountSecrets || len(w.addon.Spec.Secrets) == 0 {
+		return nil
+	}
 
-	// Check if the Workflow already exists
-	wfv1, err := w.findWorkflowByName(ctx, types.NamespacedName{Name: wp.GetName(), Namespace: wp.GetNamespace()})
+	volumes, _, err := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "volumes")
 	if err != nil {
-		return addonmgrv1alpha1.Failed, err
+		return err
+	}
+	existing := make(map[string]string)
+	for _, volume := range volumes {
+		if volume, ok := volume.(map[string]interface{}); ok {
+			name, _ := volume["name"].(string)
+			secretName, _, _ := unstructured.NestedString(volume, "secret", "secretName")
+			existing[name] = secretName
+		}
 	}
 
-	// Check if the same Addon spec was submitted and completed previously
-	if wfv1 != nil {
-		deleted, err := w.deleteCollisionWorkflows(wfv1)
-		if err != nil {
-			return addonmgrv1alpha1.Failed, err
+	var mounts []interface{}
+	for _, secret := range w.addon.Spec.Secrets {
+		if secret.Name == "" {
+			continue
 		}
-		if deleted {
-			return addonmgrv1alpha1.Pending, nil
+
+		volumeName := secretVolumeName(secret.Name)
+		if secretName, ok := existing[volumeName]; ok {
+			if secretName != secret.Name {
+				return fmt.Errorf("volume %q for secret %q already exists in the workflow", volumeName, secret.Name)
+			}
+		} else {
+			source := map[string]interface{}{"secretName": secret.Name}
+			if len(secret.Items) > 0 {
+				items := make([]interface{}, 0, len(secret.Items))
+				for i := range secret.Items {
+					item, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&secret.Items[i])
+					if err != nil {
+						return err
+					}
+					items = append(items, item)
+				}
+				source["items"] = items
+			}
+			volumes = append(volumes, map[string]interface{}{"name": volumeName, "secret": source})
+			existing[volumeName] = secret.Name
 		}
+
+		mounts = append(mounts, map[string]interface{}{
+			"name":      volumeName,
+			"mountPath": path.Join(secretsMountPath, secret.Name),
+			"readOnly":  true,
+		})
 	}
 
-	if wfv1 == nil {
-		// Create the Workflow
-		wfv1 := &unstructured.Unstructured{}
+	if len(mounts) == 0 {
+		return nil
+	}
+
+	err = unstructured.SetNestedSlice(wf.UnstructuredContent(), volumes, "spec", "volumes")
+	if err != nil {
+		return err
+	}
+
+	return addVolumeMounts(wf, mounts)
+}
 
-		// Convert proxy to workflow object
-		err = w.scheme.Convert(wp, wfv1, 0)
+// Adds the workflow type volumes to workflow.spec.volumes and mounts into the container and script templates, volumes
+// and mounts with a name or mount path already used by the template are left out
+func (w *addonWorkflows) configureVolumes(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	if len(wt.Volumes) > 0 {
+		volumes, _, err := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "volumes")
 		if err != nil {
-			return addonmgrv1alpha1.Failed, err
+			return err
 		}
-		wfv1.SetGroupVersionKind(schema.GroupVersionKind{
-			Kind:    "Workflow",
-			Group:   "argoproj.io",
-			Version: "v1alpha1",
-		})
-		wfv1.SetNamespace(wp.GetNamespace())
-		wfv1.SetName(wp.GetName())
-		// Set the owner references for workflow
-		if err := controllerutil.SetControllerReference(w.addon, wfv1, w.scheme); err != nil {
-			return addonmgrv1alpha1.Failed, err
+		names := make(map[string]bool)
+		for _, volume := range volumes {
+			if volume, ok := volume.(map[string]interface{}); ok {
+				if name, ok := volume["name"].(string); ok {
+					names[name] = true
+				}
+			}
 		}
-		ownerReferences := wfv1.GetOwnerReferences()
-		for _, ref := range ownerReferences {
-			if strings.ToLower(ref.Kind) == "addon" {
-				*ref.Controller = false
+
+		for i := range wt.Volumes {
+			if names[wt.Volumes[i].Name] {
+				continue
 			}
+			volume, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&wt.Volumes[i])
+			if err != nil {
+				return err
+			}
+			volumes = append(volumes, volume)
+			names[wt.Volumes[i].Name] = true
 		}
-		wfv1.SetOwnerReferences(ownerReferences)
 
-		err = w.Create(ctx, wfv1)
+		err = unstructured.SetNestedSlice(wf.UnstructuredContent(), volumes, "spec", "volumes")
 		if err != nil {
-			return addonmgrv1alpha1.Failed, err
+			return err
 		}
-		// Record an event for created workflow
-		w.recorder.Event(w.addon, "Normal", "Created", fmt.Sprintf("Created Workflow %s/%s", wp.GetName(), wp.GetNamespace()))
+	}
 
-		return addonmgrv1alpha1.Pending, nil
+	if len(wt.VolumeMounts) > 0 {
+		mounts := make([]interface{}, 0, len(wt.VolumeMounts))
+		for i := range wt.VolumeMounts {
+			mount, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&wt.VolumeMounts[i])
+			if err != nil {
+				return err
+			}
+			mounts = append(mounts, mount)
+		}
+		return addVolumeMounts(wf, mounts)
 	}
 
-	workflow, err := w.dynClient.Resource(common.WorkflowGVR()).Namespace(wfv1.GetNamespace()).Get(wfv1.GetName(), metav1.GetOptions{})
-	if err != nil {
-		return addonmgrv1alpha1.Failed, fmt.Errorf("could not find workflow %s/%s. %v", wfv1.GetNamespace(), wfv1.GetName(), err)
+	return nil
+}
+
+// addVolumeMounts appends the mounts to the container and script templates of the workflow, skipping mounts whose name
+// or mount path the template already uses
+func addVolumeMounts(wf *unstructured.Unstructured, mounts []interface{}) error {
+	return updateTemplates(wf, func(template map[string]interface{}) error {
+		for _, key := range []string{"container", "script"} {
+			container, ok := template[key].(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			volumeMounts, _ := container["volumeMounts"].([]interface{})
+			used := make(map[string]bool)
+			for _, volumeMount := range volumeMounts {
+				if volumeMount, ok := volumeMount.(map[string]interface{}); ok {
+					if name, ok := volumeMount["name"].(string); ok {
+						used[name] = true
+					}
+					if mountPath, ok := volumeMount["mountPath"].(string); ok {
+						used[mountPath] = true
+					}
+				}
+			}
+			for _, mount := range mounts {
+				name, _, _ := unstructured.NestedString(mount.(map[string]interface{}), "name")
+				mountPath, _, _ := unstructured.NestedString(mount.(map[string]interface{}), "mountPath")
+				if !used[name] && !used[mountPath] {
+					volumeMounts = append(volumeMounts, runtime.DeepCopyJSONValue(mount))
+					used[mountPath] = true
+				}
+			}
+			container["volumeMounts"] = volumeMounts
+		}
+		return nil
+	})
+}
+
+// secretVolumeName derives a valid volume name for a secret, secret names may contain dots which volume names can't
+func secretVolumeName(secretName string) string {
+	name := "secret-" + invalidNameCharsRegexp.ReplaceAllString(strings.ToLower(secretName), "-")
+	if len(name) > validation.DNS1123LabelMaxLength {
+		name = name[:validation.DNS1123LabelMaxLength]
 	}
+	return strings.TrimRight(name, "-")
+}
 
-	// validate workflow status
-	var phase = addonmgrv1alpha1.Pending
-	status, ok := workflow.UnstructuredContent()["status"].(map[string]interface{})
-	if ok && status["phase"] == "Succeeded" {
-		phase = addonmgrv1alpha1.Succeeded
-	} else if ok && status["phase"] == "Failed" {
-		phase = addonmgrv1alpha1.Failed
+// Appends the workflow type metrics to workflow.spec.metrics.prometheus
+func (w *addonWorkflows) configureMetrics(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	if len(wt.Metrics) == 0 {
+		return nil
 	}
 
-	return phase, nil
-}
+	metrics, _, err := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "metrics", "prometheus")
+	if err != nil {
+		return err
+	}
 
-func (w *workflowLifecycle) parse(wt *addonmgrv1alpha1.WorkflowType, wf *unstructured.Unstructured, name string) error {
-	var data map[string]interface{}
+	for _, m := range wt.Metrics {
+		if m.Name == "" || m.Help == "" {
+			return fmt.Errorf("invalid metric %q, name and help are required", m.Name)
+		}
 
-	// Load workflow spec into data obj
-	if err := yaml.Unmarshal([]byte(wt.Template), &data); err != nil {
-		return fmt.Errorf("invalid workflow yaml spec passed. %v", err)
+		metric := map[string]interface{}{
+			"name": m.Name,
+			"help": m.Help,
+		}
+		switch m.Type {
+		case "gauge":
+			value := m.Value
+			if value == "" {
+				value = "{{workflow.duration}}"
+			}
+			metric["gauge"] = map[string]interface{}{"value": value}
+		case "counter":
+			value := m.Value
+			if value == "" {
+				value = "1"
+			}
+			metric["counter"] = map[string]interface{}{"value": value}
+		default:
+			return fmt.Errorf("invalid metric %q, unknown type %q", m.Name, m.Type)
+		}
+
+		if len(m.Labels) > 0 {
+			keys := make([]string, 0, len(m.Labels))
+			for key := range m.Labels {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			labels := make([]interface{}, 0, len(keys))
+			for _, key := range keys {
+				labels = append(labels, map[string]interface{}{"key": key, "value": m.Labels[key]})
+			}
+			metric["labels"] = labels
+		}
+
+		metrics = append(metrics, metric)
 	}
 
-	wf.SetGroupVersionKind(schema.GroupVersionKind{
-		Kind:    "Workflow",
-		Group:   "argoproj.io",
-		Version: "v1alpha1",
-	})
+	return unstructured.SetNestedSlice(wf.UnstructuredContent(), metrics, "spec", "metrics", "prometheus")
+}
 
-	wf.SetNamespace(w.addon.GetNamespace())
-	wf.SetName(name)
-	content := wf.UnstructuredContent()
+// Sets retryStrategy on the container and script templates of the workflow, templates with a retryStrategy are left as is
+func (w *addonWorkflows) configureRetryStrategy(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+	rs := wt.RetryStrategy
+	if rs == nil {
+		return nil
+	}
 
-	spec, ok := data["spec"]
-	if !ok {
-		return errors.New("invalid workflow, missing spec")
+	if rs.Limit < 0 {
+		return fmt.Errorf("invalid retryStrategy, limit %d must not be negative", rs.Limit)
 	}
 
-	// Make sure workflows by default get cleaned up after 3 days
-	if ttlSecondAfterFinished := spec.(map[string]interface{})["ttlSecondsAfterFinished"]; ttlSecondAfterFinished == nil {
-		spec.(map[string]interface{})["ttlSecondsAfterFinished"] = int64(259200)
+	switch rs.RetryPolicy {
+	case "", "Always", "OnFailure", "OnError":
+	default:
+		return fmt.Errorf("invalid retryStrategy, unknown retryPolicy %q", rs.RetryPolicy)
 	}
 
-	content["spec"] = spec
-	wf.SetUnstructuredContent(content)
+	retryStrategy := map[string]interface{}{
+		"limit": int64(rs.Limit),
+	}
+	if rs.RetryPolicy != "" {
+		retryStrategy["retryPolicy"] = rs.RetryPolicy
+	}
+	if rs.Backoff != nil {
+		backoff := make(map[string]interface{})
+		if rs.Backoff.Duration != "" {
+			backoff["duration"] = rs.Backoff.Duration
+		}
+		if rs.Backoff.Factor != 0 {
+			backoff["factor"] = int64(rs.Backoff.Factor)
+		}
+		if rs.Backoff.MaxDuration != "" {
+			backoff["maxDuration"] = rs.Backoff.MaxDuration
+		}
+		retryStrategy["backoff"] = backoff
+	}
 
-	return nil
+	return updateTemplates(wf, func(template map[string]interface{}) error {
+		if template["retryStrategy"] == nil && (template["container"] != nil || template["script"] != nil) {
+			template["retryStrategy"] = runtime.DeepCopyJSONValue(retryStrategy)
+		}
+		return nil
+	})
 }
 
-func (w *workflowLifecycle) configureWorkflowArtifacts(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
+func (w *addonWorkflows) configureWorkflowArtifacts(wf *unstructured.Unstructured, wt *addonmgrv1alpha1.WorkflowType) error {
 	spec, _, _ := unstructured.NestedFieldNoCopy(wf.UnstructuredContent(), "spec")
 
 	// workflow.spec.arguments.artifacts may exist
@@ -293,25 +3483,46 @@ func (w *workflowLifecycle) configureWorkflowArtifacts(wf *unstructured.Unstruct
 		return err
 	}
 
-	templates, _, _ := unstructured.NestedFieldNoCopy(wf.UnstructuredContent(), "spec", "templates")
-	for _, template := range templates.([]interface{}) {
-		if allSteps, found, _ := unstructured.NestedFieldNoCopy(template.(map[string]interface{}), "steps"); found {
-			for _, steps := range allSteps.([]interface{}) {
-				steps := steps.([]interface{})
-				for _, step := range steps {
-					err := w.processArtifacts(step, wt)
-					if err != nil {
+	return updateTemplates(wf, func(template map[string]interface{}) error {
+		allSteps, _ := template["steps"].([]interface{})
+		for _, steps := range allSteps {
+			steps, _ := steps.([]interface{})
+			for _, step := range steps {
+				if step, ok := step.(map[string]interface{}); ok {
+					if err := w.processArtifacts(step, wt); err != nil {
 						return err
 					}
 				}
 			}
 		}
+		return nil
+	})
+}
+
+// updateTemplates calls update with every template of the workflow and writes the updated templates back, workflows
+// running a WorkflowTemplate have no templates of their own
+func updateTemplates(wf *unstructured.Unstructured, update func(template map[string]interface{}) error) error {
+	templates, found, err := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "templates")
+	if err != nil {
+		return fmt.Errorf("%w. %v", ErrInvalidTemplate, err)
+	} else if !found {
+		return nil
+	}
+
+	for _, template := range templates {
+		template, ok := template.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if err := update(template); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return unstructured.SetNestedSlice(wf.UnstructuredContent(), templates, "spec", "templates")
 }
 
-func (w *workflowLifecycle) processArtifacts(workflowStepObject interface{}, wt *addonmgrv1alpha1.WorkflowType) error {
+func (w *addonWorkflows) processArtifacts(workflowStepObject interface{}, wt *addonmgrv1alpha1.WorkflowType) error {
 	artifacts, _, _ := unstructured.NestedFieldNoCopy(workflowStepObject.(map[string]interface{}), "arguments", "artifacts")
 
 	if artifacts == nil {
@@ -361,7 +3572,7 @@ func (w *workflowLifecycle) processArtifacts(workflowStepObject interface{}, wt
 	return nil
 }
 
-func (w *workflowLifecycle) addDefaultLabelsToResource(resource map[string]interface{}) (map[string]interface{}, error) {
+func (w *addonWorkflows) addDefaultLabelsToResource(resource map[string]interface{}) (map[string]interface{}, error) {
 	metadata, _, err := unstructured.NestedMap(resource, "metadata")
 	if err != nil {
 		return nil, err
@@ -390,7 +3601,7 @@ func (w *workflowLifecycle) addDefaultLabelsToResource(resource map[string]inter
 	return resource, nil
 }
 
-func (w *workflowLifecycle) addRoleAnnotateToResource(resource map[string]interface{}, wt *addonmgrv1alpha1.WorkflowType) (map[string]interface{}, error) {
+func (w *addonWorkflows) addRoleAnnotateToResource(resource map[string]interface{}, wt *addonmgrv1alpha1.WorkflowType) (map[string]interface{}, error) {
 	metadata, found, _ := unstructured.NestedMap(resource, "spec", "template", "metadata")
 	if !found {
 		err := unstructured.SetNestedMap(resource, make(map[string]interface{}), "spec", "template", "metadata")
@@ -419,12 +3630,23 @@ func (w *workflowLifecycle) addRoleAnnotateToResource(resource map[string]interf
 	return resource, nil
 }
 
-func (w *workflowLifecycle) deleteCollisionWorkflows(wfv1 *unstructured.Unstructured) (bool, error) {
+// applyWorkflow submits the workflow with a server-side apply patch, applying a workflow that exists with the same
+// fields leaves it unchanged
+func (w *addonWorkflows) applyWorkflow(wf *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	data, err := wf.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	return w.dynClient.Resource(w.gvr).Namespace(wf.GetNamespace()).Patch(wf.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{FieldManager: w.fieldManager})
+}
+
+func (w *addonWorkflows) deleteCollisionWorkflows(ctx context.Context, wfv1 *unstructured.Unstructured) (bool, error) {
 	var mostRecentWorkflowTime time.Time
 	var mostRecentWorkflow unstructured.Unstructured
 	var deleted = false
 
-	workflows, err := w.dynClient.Resource(common.WorkflowGVR()).Namespace(w.addon.GetNamespace()).List(metav1.ListOptions{})
+	workflows, err := w.dynClient.Resource(w.gvr).Namespace(w.workflowNamespace()).List(metav1.ListOptions{})
 	if err != nil {
 		return false, fmt.Errorf("failed to list workflows. %v", err)
 	}
@@ -432,10 +3654,11 @@ func (w *workflowLifecycle) deleteCollisionWorkflows(wfv1 *unstructured.Unstruct
 	// Get the most recently run workflow for this addon
 	for _, workflow := range workflows.Items {
 		if strings.Contains(workflow.GetName(), w.addon.Name) {
-			if workflow.UnstructuredContent()["status"] == nil {
+			// Workflows that haven't started yet are still in flight
+			startedAt, found, _ := unstructured.NestedString(workflow.UnstructuredContent(), "status", "startedAt")
+			if !found {
 				return false, nil
 			}
-			startedAt := workflow.UnstructuredContent()["status"].(map[string]interface{})["startedAt"].(string)
 			t, err := time.Parse(time.RFC3339, startedAt)
 			if err != nil {
 				return false, err
@@ -454,9 +3677,9 @@ func (w *workflowLifecycle) deleteCollisionWorkflows(wfv1 *unstructured.Unstruct
 	// If the most recently run workflow doesn't have the current checksum, delete the old checksum workflows
 	if !strings.Contains(mostRecentWorkflow.GetName(), w.addon.Status.Checksum) {
 		for _, workflow := range workflows.Items {
-			phase := workflow.UnstructuredContent()["status"].(map[string]interface{})["phase"].(string)
+			phase, _, _ := unstructured.NestedString(workflow.UnstructuredContent(), "status", "phase")
 			if strings.Contains(workflow.GetName(), w.addon.Status.Checksum) && phase != "Pending" {
-				_ = w.Delete(workflow.GetName())
+				_ = w.Delete(ctx, workflow.GetName())
 				deleted = true
 			}
 		}
@@ -16,16 +16,30 @@ package workflows
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/adler32"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/go-logr/logr"
 	. "github.com/onsi/gomega"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	discoveryfake "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/dynamic"
 	dynfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/record"
 	runtimefake "sigs.k8s.io/controller-runtime/pkg/client/fake"
 
@@ -90,15 +104,45 @@ func init() {
 	})
 	sch.AddKnownTypes(common.AddonGVR().GroupVersion(), &v1alpha1.Addon{}, &v1alpha1.AddonList{})
 	sch.AddKnownTypes(common.WorkflowGVR().GroupVersion(), wf, wfList)
+	sch.AddKnownTypes(v1.SchemeGroupVersion, &v1.ConfigMap{}, &v1.ConfigMapList{})
 	metav1.AddToGroupVersion(sch, common.WorkflowGVR().GroupVersion())
 }
 
+// newTestAddon returns an addon of the package in the default namespace
+func newTestAddon(name, pkgName string) *v1alpha1.Addon {
+	return &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			UID:       "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5",
+		},
+		Spec: v1alpha1.AddonSpec{
+			PackageSpec: v1alpha1.PackageSpec{
+				PkgName:    pkgName,
+				PkgVersion: "1.0.0",
+				PkgType:    v1alpha1.HelmPkg,
+			},
+		},
+	}
+}
+
+// newTestWorkflow returns a workflow in the default namespace, its status is only set for a phase
+func newTestWorkflow(name, phase string) *unstructured.Unstructured {
+	wf := &unstructured.Unstructured{}
+	wf.SetAPIVersion("argoproj.io/v1alpha1")
+	wf.SetKind("Workflow")
+	wf.SetNamespace("default")
+	wf.SetName(name)
+	if phase != "" {
+		_ = unstructured.SetNestedField(wf.Object, phase, "status", "phase")
+	}
+	return wf
+}
+
 func TestNewWorkflowLifecycle(t *testing.T) {
 	g := NewGomegaWithT(t)
 
-	a := &v1alpha1.Addon{}
-
-	wfl := NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch)
+	wfl := NewWorkflowLifecycle(fclient, dynClient, rcdr, sch)
 
 	var expected AddonLifecycle = &workflowLifecycle{}
 	g.Expect(wfl).To(BeAssignableToTypeOf(expected))
@@ -111,6 +155,7 @@ func TestWorkflowLifecycle_Install(t *testing.T) {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "foo",
 			Namespace: "default",
+			UID:       "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5",
 		},
 		Spec: v1alpha1.AddonSpec{
 			PackageSpec: v1alpha1.PackageSpec{
@@ -128,7 +173,8 @@ func TestWorkflowLifecycle_Install(t *testing.T) {
 		},
 	}
 
-	wfl := NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch)
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
 
 	wt := &v1alpha1.WorkflowType{
 		NamePrefix: "test",
@@ -136,10 +182,287 @@ func TestWorkflowLifecycle_Install(t *testing.T) {
 		Template:   wfSpecTemplate,
 	}
 
-	phase, err := wfl.Install(context.Background(), wt, "addon-wf-test")
+	phase, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	g.Expect(name).To(Equal("addon-wf-test"))
+}
+
+// Test that an empty name relies on generateName and returns the server assigned name
+func TestWorkflowLifecycle_Install_GenerateName(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	// The fake client doesn't generate names, so mimic the API server
+	dyn.PrependReactor("create", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		obj := action.(clienttesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		if obj.GetName() == "" {
+			obj.SetName(obj.GetGenerateName() + "abcde")
+		}
+		return false, nil, nil
+	})
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	wt := &v1alpha1.WorkflowType{
+		Template: wfSpecTemplate,
+	}
+
+	phase, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "")
+
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	g.Expect(name).To(Equal("my-addon-abcde"))
+
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	// A failed submission names the workflow by its generateName
+	dyn = dynfake.NewSimpleDynamicClient(sch)
+	dyn.PrependReactor("create", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("unavailable")
+	})
+	recorder := record.NewFakeRecorder(10)
+	wfl = NewWorkflowLifecycle(fclient, dyn, recorder, sch)
+
+	_, _, err = wfl.Install(context.Background(), a, v1alpha1.Install, wt, "")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(<-recorder.Events).To(Equal("Warning WorkflowSubmitFailed Failed to submit workflow with generateName my-addon- for addon default/foo. unavailable"))
+}
+
+func TestWorkflowLifecycle_Install_Existing(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.AddonSpec{
+			PackageSpec: v1alpha1.PackageSpec{
+				PkgName:        "my-addon",
+				PkgVersion:     "1.0.0",
+				PkgType:        v1alpha1.HelmPkg,
+				PkgDescription: "",
+			},
+		},
+	}
+
+	// The workflow was just submitted, it isn't in the controller cache yet
+	existing := newTestWorkflow("addon-wf-test", "Succeeded")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch, existing)
+	recorder := record.NewFakeRecorder(10)
+	wfl := NewWorkflowLifecycle(fclient, dyn, recorder, sch)
+
+	phase, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Succeeded))
+	g.Expect(name).To(Equal("addon-wf-test"))
+	for _, action := range dyn.Actions() {
+		g.Expect(action.GetVerb()).To(Not(Equal("create")))
+	}
+	g.Expect(recorder.Events).To(BeEmpty())
+}
+
+func TestWorkflowLifecycle_Install_NameStrategy(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	// The fake client doesn't generate names, so mimic the API server
+	dyn.PrependReactor("create", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		obj := action.(clienttesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		if obj.GetName() == "" {
+			obj.SetName(obj.GetGenerateName() + "abcde")
+		}
+		return false, nil, nil
+	})
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	// Generate ignores the given name
+	phase, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, NameStrategy: v1alpha1.GenerateName}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	g.Expect(name).To(Equal("my-addon-abcde"))
+
+	// Explicit requires a name
+	phase, name, err = wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, NameStrategy: v1alpha1.ExplicitName}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	g.Expect(name).To(Equal("addon-wf-test"))
+
+	phase, _, err = wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, NameStrategy: v1alpha1.ExplicitName}, "")
+	g.Expect(err).To(MatchError("invalid workflow name, the Explicit nameStrategy requires a name"))
+	g.Expect(phase).To(Equal(v1alpha1.Failed))
+
+	phase, _, err = wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, NameStrategy: "Random"}, "addon-wf-random")
+	g.Expect(err).To(MatchError(`invalid nameStrategy "Random"`))
+	g.Expect(phase).To(Equal(v1alpha1.Failed))
+
+	// Delete workflows are looked up by name
+	phase, err = wfl.RunDeleteWorkflow(context.Background(), a, &v1alpha1.WorkflowType{Template: wfSpecTemplate, NameStrategy: v1alpha1.GenerateName})
+	g.Expect(err).To(MatchError("invalid nameStrategy, delete workflows must use an Explicit name"))
+	g.Expect(phase).To(Equal(v1alpha1.DeleteFailed))
+}
+
+func TestWorkflowLifecycle_Install_ServerSideApply(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	// The fake client doesn't support apply patches, so mimic the API server
+	applied := make(map[string]*unstructured.Unstructured)
+	dyn.PrependReactor("patch", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		patch := action.(clienttesting.PatchAction)
+		if patch.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+		if existing, ok := applied[patch.GetName()]; ok {
+			return true, existing.DeepCopy(), nil
+		}
+		wf := &unstructured.Unstructured{}
+		if err := wf.UnmarshalJSON(patch.GetPatch()); err != nil {
+			return true, nil, err
+		}
+		applied[patch.GetName()] = wf
+		return true, wf.DeepCopy(), nil
+	})
+	wfl := NewWorkflowLifecycleWithOptions(fclient, dyn, rcdr, sch, WithServerSideApply())
 
+	wt := &v1alpha1.WorkflowType{Template: wfSpecTemplate}
+	phase, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
 	g.Expect(err).To(Not(HaveOccurred()))
 	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	g.Expect(name).To(Equal("addon-wf-test"))
+	g.Expect(applied).To(HaveKey("addon-wf-test"))
+	g.Expect(applied["addon-wf-test"].GetKind()).To(Equal("Workflow"))
+	g.Expect(applied["addon-wf-test"].GetOwnerReferences()).To(HaveLen(1))
+
+	// Applying again adopts the workflow, e.g. once argo started it
+	_ = unstructured.SetNestedField(applied["addon-wf-test"].Object, "Running", "status", "phase")
+	phase, name, err = wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Running))
+	g.Expect(name).To(Equal("addon-wf-test"))
+	g.Expect(applied).To(HaveLen(1))
+
+	// Workflows the lookup finds are applied too, instead of being reported as is
+	existing := newTestWorkflow("addon-wf-existing", "")
+	existing.SetAnnotations(map[string]string{WorkflowChecksumAnnotation: "stale"})
+	_ = unstructured.SetNestedField(existing.Object, "Running", "status", "phase")
+	applied["addon-wf-existing"] = existing.DeepCopy()
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Create(existing.DeepCopy(), metav1.CreateOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	dyn.ClearActions()
+
+	action, err := wfl.PlanInstall(context.Background(), a, v1alpha1.Install, wt, "addon-wf-existing")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(action).To(Equal(InstallActionApply))
+
+	phase, name, err = wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-existing")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Running))
+	g.Expect(name).To(Equal("addon-wf-existing"))
+	g.Expect(dyn.Actions()).To(ContainElement(WithTransform(func(action clienttesting.Action) string {
+		if patch, ok := action.(clienttesting.PatchAction); ok {
+			return patch.GetName()
+		}
+		return ""
+	}, Equal("addon-wf-existing"))))
+
+	for _, action := range dyn.Actions() {
+		g.Expect(action.GetVerb()).To(Not(Equal("create")))
+		if patch, ok := action.(clienttesting.PatchAction); ok {
+			g.Expect(patch.GetPatchType()).To(Equal(types.ApplyPatchType))
+		}
+	}
+}
+
+// fieldManagerClient records the field managers the workflows are created and patched with, the fake client actions
+// don't carry the request options
+type fieldManagerClient struct {
+	dynamic.Interface
+	managers *[]string
+}
+
+func (c *fieldManagerClient) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return &fieldManagerResource{NamespaceableResourceInterface: c.Interface.Resource(gvr), managers: c.managers}
+}
+
+type fieldManagerResource struct {
+	dynamic.NamespaceableResourceInterface
+	managers *[]string
+}
+
+func (r *fieldManagerResource) Namespace(ns string) dynamic.ResourceInterface {
+	return &fieldManagerResource{NamespaceableResourceInterface: r.NamespaceableResourceInterface.Namespace(ns).(dynamic.NamespaceableResourceInterface), managers: r.managers}
+}
+
+func (r *fieldManagerResource) Create(obj *unstructured.Unstructured, options metav1.CreateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	*r.managers = append(*r.managers, options.FieldManager)
+	return r.NamespaceableResourceInterface.Create(obj, options, subresources...)
+}
+
+func (r *fieldManagerResource) Patch(name string, pt types.PatchType, data []byte, options metav1.PatchOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	*r.managers = append(*r.managers, options.FieldManager)
+	return r.NamespaceableResourceInterface.Patch(name, pt, data, options, subresources...)
+}
+
+func TestWorkflowLifecycle_WithFieldManager(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+	wt := &v1alpha1.WorkflowType{Template: wfSpecTemplate}
+
+	// The addon manager is the default field manager
+	var managers []string
+	dyn := &fieldManagerClient{Interface: dynfake.NewSimpleDynamicClient(sch), managers: &managers}
+	wfl := NewWorkflowLifecycleWithOptions(fclient, dyn, rcdr, sch)
+	_, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(managers).To(Equal([]string{"addon-manager"}))
+
+	// The configured field manager is used to create and patch workflows
+	managers = nil
+	dyn = &fieldManagerClient{Interface: dynfake.NewSimpleDynamicClient(sch), managers: &managers}
+	wfl = NewWorkflowLifecycleWithOptions(fclient, dyn, rcdr, sch, WithFieldManager("my-controller"))
+	_, _, err = wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(wfl.Suspend(context.Background(), a, "addon-wf-test")).To(Succeed())
+	g.Expect(managers).To(Equal([]string{"my-controller", "my-controller"}))
+}
+
+func TestWorkflowLifecycle_WorkflowGenerateName(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	longName := "addon-with-a-very-long-package-name-that-does-not-fit-in-a-workflow-name"
+	tests := []struct {
+		pkgName    string
+		namePrefix string
+		expected   string
+	}{
+		{pkgName: "my-addon", expected: "my-addon-"},
+		{pkgName: "my-addon", namePrefix: "prereqs", expected: "prereqs-my-addon-"},
+		{pkgName: "Core/My_Addon", expected: "core-my-addon-"},
+		{pkgName: "", expected: "foo-"},
+		{pkgName: longName, expected: fmt.Sprintf("addon-with-a-very-long-package-name-that-does-no-%08x-", adler32.Checksum([]byte(longName)))},
+		{pkgName: longName, namePrefix: "prereqs", expected: fmt.Sprintf("prereqs-addon-with-a-very-long-package-name-that-%08x-", adler32.Checksum([]byte("prereqs-"+longName)))},
+	}
+
+	for _, tt := range tests {
+		a := newTestAddon("foo", tt.pkgName)
+
+		wfl := NewWorkflowLifecycle(fclient, dynfake.NewSimpleDynamicClient(sch), rcdr, sch)
+		wf, err := wfl.DryRunInstall(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, NamePrefix: tt.namePrefix}, "")
+		g.Expect(err).To(Not(HaveOccurred()))
+		g.Expect(wf.GetGenerateName()).To(Equal(tt.expected), tt.pkgName)
+		g.Expect(len(wf.GetGenerateName())).To(BeNumerically("<=", 58))
+	}
 }
 
 // Test that an empty workflow type will fail
@@ -150,6 +473,7 @@ func TestWorkflowLifecycle_Install_InvalidWorkflowType(t *testing.T) {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "foo",
 			Namespace: "default",
+			UID:       "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5",
 		},
 		Spec: v1alpha1.AddonSpec{
 			PackageSpec: v1alpha1.PackageSpec{
@@ -167,12 +491,12 @@ func TestWorkflowLifecycle_Install_InvalidWorkflowType(t *testing.T) {
 		},
 	}
 
-	wfl := NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch)
+	wfl := NewWorkflowLifecycle(fclient, dynClient, rcdr, sch)
 
 	// Empty workflow type should fail
 	wt := &v1alpha1.WorkflowType{}
 
-	phase, err := wfl.Install(context.Background(), wt, "addon-wf-test")
+	phase, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
 
 	g.Expect(err).To(HaveOccurred())
 	g.Expect(phase).To(Equal(v1alpha1.Failed))
@@ -185,6 +509,7 @@ func TestWorkflowLifecycle_Delete_NotExists(t *testing.T) {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "foo",
 			Namespace: "default",
+			UID:       "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5",
 		},
 		Spec: v1alpha1.AddonSpec{
 			PackageSpec: v1alpha1.PackageSpec{
@@ -202,9 +527,104 @@ func TestWorkflowLifecycle_Delete_NotExists(t *testing.T) {
 		},
 	}
 
-	wfl := NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch)
+	wfl := NewWorkflowLifecycle(fclient, dynClient, rcdr, sch)
+
+	g.Expect(wfl.Delete(context.Background(), a, "addon-wf-test")).To(Succeed())
+	g.Expect(wfl.DeleteStrict(context.Background(), a, "addon-wf-test")).To(HaveOccurred())
+}
+
+// Test that errors other than not found are returned from delete
+func TestWorkflowLifecycle_Delete_Error(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	dyn.PrependReactor("delete", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("apiserver unavailable")
+	})
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	g.Expect(wfl.Delete(context.Background(), a, "addon-wf-test")).To(MatchError("apiserver unavailable"))
+}
+
+// Test that delete does not call the apiserver once the context is cancelled
+func TestWorkflowLifecycle_Delete_Cancelled(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	g.Expect(wfl.Delete(ctx, a, "addon-wf-test")).To(Equal(context.Canceled))
+	g.Expect(dyn.Actions()).To(BeEmpty())
+}
+
+func TestWorkflowLifecycle_ForceDelete(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+			UID:       "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5",
+		},
+	}
+
+	wf := newTestWorkflow("addon-wf-stuck", "")
+	wf.SetFinalizers([]string{"workflows.argoproj.io/artifact-gc"})
+
+	dyn := dynfake.NewSimpleDynamicClient(sch, wf)
+	// The apiserver only removes the workflow once its finalizers are cleared
+	finalizers := wf.GetFinalizers()
+	dyn.PrependReactor("patch", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		patch := &unstructured.Unstructured{}
+		if err := json.Unmarshal(action.(clienttesting.PatchAction).GetPatch(), &patch.Object); err == nil {
+			if f, ok, _ := unstructured.NestedFieldNoCopy(patch.Object, "metadata", "finalizers"); ok && f == nil {
+				finalizers = nil
+			}
+		}
+		return false, nil, nil
+	})
+	dyn.PrependReactor("delete", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if len(finalizers) > 0 {
+			return true, nil, errors.New("workflow has finalizers")
+		}
+		return false, nil, nil
+	})
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	g.Expect(wfl.Delete(context.Background(), a, "addon-wf-stuck")).To(MatchError("workflow has finalizers"))
+	g.Expect(wfl.ForceDelete(context.Background(), a, "addon-wf-stuck")).To(Succeed())
+
+	_, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get("addon-wf-stuck", metav1.GetOptions{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+	err = wfl.ForceDelete(context.Background(), a, "addon-wf-stuck")
+	g.Expect(errors.Is(err, ErrWorkflowNotFound)).To(BeTrue())
+}
+
+// Test that status is not read from the apiserver once the context is cancelled
+func TestWorkflowLifecycle_GetStatus_Cancelled(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
 
-	g.Expect(wfl.Delete("addon-wf-test")).To(HaveOccurred())
+	_, err := wfl.GetStatus(ctx, a, "addon-wf-test")
+	g.Expect(err).To(Equal(context.Canceled))
+	_, err = wfl.GetOutputs(ctx, a, "addon-wf-test")
+	g.Expect(err).To(Equal(context.Canceled))
+	g.Expect(dyn.Actions()).To(BeEmpty())
 }
 
 func TestNewWorkflowLifecycle_Delete(t *testing.T) {
@@ -214,6 +634,7 @@ func TestNewWorkflowLifecycle_Delete(t *testing.T) {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "foo",
 			Namespace: "default",
+			UID:       "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5",
 		},
 		Spec: v1alpha1.AddonSpec{
 			PackageSpec: v1alpha1.PackageSpec{
@@ -231,7 +652,7 @@ func TestNewWorkflowLifecycle_Delete(t *testing.T) {
 		},
 	}
 
-	wfl := NewWorkflowLifecycle(fclient, dynClient, a, rcdr, sch)
+	wfl := NewWorkflowLifecycle(fclient, dynClient, rcdr, sch)
 
 	wf := &unstructured.Unstructured{}
 	wf.SetGroupVersionKind(schema.GroupVersionKind{
@@ -247,5 +668,4093 @@ func TestNewWorkflowLifecycle_Delete(t *testing.T) {
 	g.Expect(err).To(Not(HaveOccurred()))
 
 	// Now try to delete
-	g.Expect(wfl.Delete("addon-wf-test")).To(Not(HaveOccurred()))
+	g.Expect(wfl.Delete(context.Background(), a, "addon-wf-test")).To(Not(HaveOccurred()))
+}
+
+func TestWorkflowLifecycle_SuspendResume(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	wf := newTestWorkflow("addon-wf-test", "Running")
+
+	_, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Create(wf, metav1.CreateOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	g.Expect(wfl.Suspend(context.Background(), a, "addon-wf-test")).To(Succeed())
+
+	found, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get("addon-wf-test", metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	suspended, _, _ := unstructured.NestedBool(found.UnstructuredContent(), "spec", "suspend")
+	g.Expect(suspended).To(BeTrue())
+
+	// Suspending again is a no-op
+	g.Expect(wfl.Suspend(context.Background(), a, "addon-wf-test")).To(Succeed())
+
+	g.Expect(wfl.Resume(context.Background(), a, "addon-wf-test")).To(Succeed())
+
+	found, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get("addon-wf-test", metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	suspended, _, _ = unstructured.NestedBool(found.UnstructuredContent(), "spec", "suspend")
+	g.Expect(suspended).To(BeFalse())
+
+	// Nothing is patched once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	g.Expect(wfl.Suspend(ctx, a, "addon-wf-test")).To(MatchError(context.Canceled))
+
+	found, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get("addon-wf-test", metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	suspended, _, _ = unstructured.NestedBool(found.UnstructuredContent(), "spec", "suspend")
+	g.Expect(suspended).To(BeFalse())
+}
+
+func TestWorkflowLifecycle_Suspend_Completed(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+			UID:       "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5",
+		},
+	}
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	wf := newTestWorkflow("addon-wf-test", "Succeeded")
+
+	_, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Create(wf, metav1.CreateOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	err = wfl.Suspend(context.Background(), a, "addon-wf-test")
+	g.Expect(errors.Is(err, ErrWorkflowCompleted)).To(BeTrue())
+	g.Expect(err).To(MatchError("default/addon-wf-test: workflow has already completed"))
+	err = wfl.Resume(context.Background(), a, "addon-wf-test")
+	g.Expect(errors.Is(err, ErrWorkflowCompleted)).To(BeTrue())
+
+	err = wfl.Suspend(context.Background(), a, "addon-wf-missing")
+	g.Expect(errors.Is(err, ErrWorkflowNotFound)).To(BeTrue())
+}
+
+func TestWorkflowLifecycle_Install_TTLStrategy(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	// Default ttl is used when none is given
+	wt := &v1alpha1.WorkflowType{
+		Template: wfSpecTemplate,
+	}
+
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-default-ttl")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	ttl, found, _ := unstructured.NestedInt64(wf.UnstructuredContent(), "spec", "ttlStrategy", "secondsAfterCompletion")
+	g.Expect(found).To(BeTrue())
+	g.Expect(ttl).To(Equal(int64(defaultTTLSecondsAfterCompletion)))
+	_, found, _ = unstructured.NestedFieldNoCopy(wf.UnstructuredContent(), "spec", "ttlSecondsAfterFinished")
+	g.Expect(found).To(BeFalse())
+
+	// Configured ttl overrides the default
+	var seconds int32 = 600
+	wt = &v1alpha1.WorkflowType{
+		Template:                  wfSpecTemplate,
+		TTLSecondsAfterCompletion: &seconds,
+	}
+
+	_, name, err = wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-ttl")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	ttl, found, _ = unstructured.NestedInt64(wf.UnstructuredContent(), "spec", "ttlStrategy", "secondsAfterCompletion")
+	g.Expect(found).To(BeTrue())
+	g.Expect(ttl).To(Equal(int64(600)))
+}
+
+func TestWorkflowLifecycle_GetStatus(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+			UID:       "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5",
+		},
+	}
+
+	tests := []struct {
+		wfPhase  string
+		expected v1alpha1.ApplicationAssemblyPhase
+	}{
+		{wfPhase: "", expected: v1alpha1.Pending},
+		{wfPhase: "Pending", expected: v1alpha1.Pending},
+		{wfPhase: "Running", expected: v1alpha1.Running},
+		{wfPhase: "Succeeded", expected: v1alpha1.Succeeded},
+		{wfPhase: "Failed", expected: v1alpha1.Failed},
+		{wfPhase: "Error", expected: v1alpha1.Failed},
+	}
+
+	for _, tt := range tests {
+		dyn := dynfake.NewSimpleDynamicClient(sch)
+		wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+		wf := newTestWorkflow("addon-wf-test", "")
+		if tt.wfPhase != "" {
+			_ = unstructured.SetNestedField(wf.UnstructuredContent(), tt.wfPhase, "status", "phase")
+		}
+
+		_, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Create(wf, metav1.CreateOptions{})
+		g.Expect(err).To(Not(HaveOccurred()))
+
+		phase, err := wfl.GetStatus(context.Background(), a, "addon-wf-test")
+		g.Expect(err).To(Not(HaveOccurred()))
+		g.Expect(phase).To(Equal(tt.expected), "workflow phase %q", tt.wfPhase)
+	}
+}
+
+func TestWorkflowLifecycle_GetStatus_NotFound(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+			UID:       "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5",
+		},
+	}
+
+	wfl := NewWorkflowLifecycle(fclient, dynfake.NewSimpleDynamicClient(sch), rcdr, sch)
+
+	_, err := wfl.GetStatus(context.Background(), a, "addon-wf-missing")
+	g.Expect(errors.Is(err, ErrWorkflowNotFound)).To(BeTrue())
+	g.Expect(err).To(MatchError("default/addon-wf-missing: workflow not found"))
+
+	_, err = wfl.Retry(context.Background(), a, "addon-wf-missing")
+	g.Expect(errors.Is(err, ErrWorkflowNotFound)).To(BeTrue())
+
+	_, err = wfl.GetOutputs(context.Background(), a, "addon-wf-missing")
+	g.Expect(errors.Is(err, ErrWorkflowNotFound)).To(BeTrue())
+
+	_, err = wfl.GetFailedNodeMessages(context.Background(), a, "addon-wf-missing")
+	g.Expect(errors.Is(err, ErrWorkflowNotFound)).To(BeTrue())
+
+	_, err = wfl.WorkflowConditions(context.Background(), a, "addon-wf-missing")
+	g.Expect(errors.Is(err, ErrWorkflowNotFound)).To(BeTrue())
+}
+
+func TestRequeueAfter(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tests := []struct {
+		phase    v1alpha1.ApplicationAssemblyPhase
+		expected time.Duration
+	}{
+		{v1alpha1.Pending, requeueInterval},
+		{v1alpha1.Running, requeueInterval},
+		{v1alpha1.Deleting, requeueInterval},
+		{v1alpha1.Succeeded, 0},
+		{v1alpha1.Failed, 0},
+		{v1alpha1.DeleteFailed, 0},
+		{"", 0},
+	}
+
+	for _, tc := range tests {
+		g.Expect(RequeueAfter(tc.phase)).To(Equal(tc.expected), string(tc.phase))
+	}
+}
+
+func TestEntrypointOf(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	entrypoint, err := EntrypointOf(&v1alpha1.WorkflowType{Template: wfSpecTemplate})
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(entrypoint).To(Equal("python-script-example"))
+
+	// The entrypoint is required
+	_, err = EntrypointOf(&v1alpha1.WorkflowType{Template: strings.Replace(wfSpecTemplate, "entrypoint: python-script-example", "", 1)})
+	g.Expect(err).To(MatchError("invalid workflow, missing spec.entrypoint"))
+
+	// The entrypoint must be one of the templates
+	_, err = EntrypointOf(&v1alpha1.WorkflowType{Template: strings.Replace(wfSpecTemplate, "entrypoint: python-script-example", "entrypoint: main", 1)})
+	g.Expect(err).To(MatchError(`invalid workflow, entrypoint "main" is not defined in spec.templates`))
+
+	_, err = EntrypointOf(&v1alpha1.WorkflowType{Template: "spec: ["})
+	g.Expect(err).To(HaveOccurred())
+}
+
+// Test that templates argo can't run are rejected before submission
+func TestWorkflowLifecycle_Install_InvalidTemplate(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+			UID:       "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5",
+		},
+	}
+
+	templates := map[string]string{
+		"malformed yaml": "apiVersion: argoproj.io/v1alpha1\nkind: Workflow\nspec: [entrypoint",
+		"wrong kind": `
+apiVersion: argoproj.io/v1alpha1
+kind: CronWorkflow
+spec:
+  entrypoint: entry
+`,
+		"missing entrypoint": `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+spec:
+  templates:
+    - name: entry
+`,
+	}
+
+	for desc, template := range templates {
+		dyn := dynfake.NewSimpleDynamicClient(sch)
+		wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+		phase, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: template}, "addon-wf-test")
+		g.Expect(errors.Is(err, ErrInvalidTemplate)).To(BeTrue(), desc)
+		g.Expect(phase).To(Equal(v1alpha1.Failed), desc)
+
+		wfs, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").List(metav1.ListOptions{})
+		g.Expect(err).To(Not(HaveOccurred()))
+		g.Expect(wfs.Items).To(BeEmpty(), desc)
+	}
+}
+
+// Test that templates argo runs without a pod, like http templates, are accepted
+func TestWorkflowLifecycle_Install_HTTPTemplate(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	template := `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+spec:
+  entrypoint: notify
+  templates:
+    - name: notify
+      http:
+        url: https://hooks.example.com/addons
+        method: POST
+        body: '{"addon": "{{workflow.parameters.namespace}}"}'
+        successCondition: response.statusCode == 200
+`
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	wt := &v1alpha1.WorkflowType{
+		Template:        template,
+		ImagePullPolicy: "Always",
+		Env:             []v1.EnvVar{{Name: "HTTPS_PROXY", Value: "http://proxy:3128"}},
+		VolumeMounts:    []v1.VolumeMount{{Name: "helm-cache", MountPath: "/cache"}},
+	}
+	phase, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+
+	// The http template is submitted untouched
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	templates, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "templates")
+	g.Expect(templates).To(Equal([]interface{}{
+		map[string]interface{}{
+			"name": "notify",
+			"http": map[string]interface{}{
+				"url":              "https://hooks.example.com/addons",
+				"method":           "POST",
+				"body":             `{"addon": "{{workflow.parameters.namespace}}"}`,
+				"successCondition": "response.statusCode == 200",
+			},
+		},
+	}))
+}
+
+func TestWorkflowLifecycle_Install_ServiceAccount(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+			UID:       "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5",
+		},
+	}
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	// Role is used as the service account
+	wt := &v1alpha1.WorkflowType{
+		Role:     "addon-installer",
+		Template: wfSpecTemplate,
+	}
+
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-role")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	sa, found, _ := unstructured.NestedString(wf.UnstructuredContent(), "spec", "serviceAccountName")
+	g.Expect(found).To(BeTrue())
+	g.Expect(sa).To(Equal("addon-installer"))
+
+	// No role leaves the service account unset
+	wt = &v1alpha1.WorkflowType{
+		Template: wfSpecTemplate,
+	}
+
+	_, name, err = wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-no-role")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	_, found, _ = unstructured.NestedString(wf.UnstructuredContent(), "spec", "serviceAccountName")
+	g.Expect(found).To(BeFalse())
+}
+
+func TestWorkflowLifecycle_Install_OwnerReference(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+			UID:       "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5",
+		},
+	}
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	refs := wf.GetOwnerReferences()
+	g.Expect(refs).To(HaveLen(1))
+	g.Expect(refs[0].APIVersion).To(Equal("addonmgr.keikoproj.io/v1alpha1"))
+	g.Expect(refs[0].Kind).To(Equal("Addon"))
+	g.Expect(refs[0].Name).To(Equal("foo"))
+	g.Expect(refs[0].UID).To(Equal(a.UID))
+	g.Expect(*refs[0].Controller).To(BeTrue())
+	g.Expect(*refs[0].BlockOwnerDeletion).To(BeTrue())
+}
+
+// Test that a workflow is not created without an owner
+func TestWorkflowLifecycle_Install_NoAddonUID(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+		},
+	}
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	phase, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(phase).To(Equal(v1alpha1.Failed))
+
+	wfs, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").List(metav1.ListOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(wfs.Items).To(BeEmpty())
+}
+
+func TestWorkflowLifecycle_Install_Labels(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+	a.Labels = map[string]string{
+		"team":        "platform",
+		"cost-center": "addon",
+		"internal":    "true",
+	}
+	a.Annotations = map[string]string{
+		PropagateLabelsAnnotation: "team, cost-center",
+	}
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	wt := &v1alpha1.WorkflowType{
+		Template: `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  labels:
+    cost-center: workflows
+spec:
+  entrypoint: entry
+  templates:
+    - name: entry
+      container:
+        image: alpine:latest
+`,
+	}
+
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	labels := wf.GetLabels()
+	g.Expect(labels).To(HaveKeyWithValue("app.kubernetes.io/name", "my-addon"))
+	g.Expect(labels).To(HaveKeyWithValue(WorkflowVersionLabel, "1.0.0"))
+	g.Expect(labels).To(HaveKeyWithValue(WorkflowAddonLabel, "foo"))
+	g.Expect(labels).To(HaveKeyWithValue(WorkflowStepLabel, "install"))
+	g.Expect(labels).To(HaveKeyWithValue("team", "platform"))
+	g.Expect(labels).To(Not(HaveKey("internal")))
+	// Template labels win on collisions
+	g.Expect(labels).To(HaveKeyWithValue("cost-center", "workflows"))
+
+	g.Expect(wf.GetAnnotations()).To(HaveKeyWithValue(WorkflowSourceAnnotation, "default/foo"))
+
+	// Addon labels are not copied unless the addon lists them
+	b := a.DeepCopy()
+	b.Annotations = nil
+	wfl = NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+	_, name, err = wfl.Install(context.Background(), b, v1alpha1.Install, wt, "addon-wf-default")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(wf.GetLabels()).To(Not(HaveKey("team")))
+	g.Expect(wf.GetLabels()).To(HaveKeyWithValue("app.kubernetes.io/name", "my-addon"))
+}
+
+func TestWorkflowLifecycle_Install_KustomizePkg(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+	a.Spec.PkgType = v1alpha1.KustomizePkg
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	phase, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	params, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "arguments", "parameters")
+	g.Expect(params).To(ContainElement(map[string]interface{}{"name": "pkgType", "value": "kustomize"}))
+
+	// An unset package type is not injected
+	a.Spec.PkgType = ""
+	wfl = NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+	_, name, err = wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-unset")
+	g.Expect(err).To(Not(HaveOccurred()))
+	wf, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	params, _, _ = unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "arguments", "parameters")
+	for _, param := range params {
+		g.Expect(param.(map[string]interface{})["name"]).To(Not(Equal("pkgType")))
+	}
+
+	// A pkgType declared by the template wins
+	a.Spec.PkgType = v1alpha1.KustomizePkg
+	wfl = NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+	withPkgType := `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+spec:
+  entrypoint: install
+  arguments:
+    parameters:
+      - name: pkgType
+        value: helm
+  templates:
+    - name: install
+      container:
+        image: alpine:latest
+`
+	_, name, err = wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: withPkgType}, "addon-wf-declared")
+	g.Expect(err).To(Not(HaveOccurred()))
+	wf, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	params, _, _ = unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "arguments", "parameters")
+	g.Expect(params).To(ContainElement(map[string]interface{}{"name": "pkgType", "value": "helm"}))
+	g.Expect(params).To(Not(ContainElement(map[string]interface{}{"name": "pkgType", "value": "kustomize"})))
+}
+
+func TestWorkflowLifecycle_Retry(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+		},
+	}
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	wf := newTestWorkflow("addon-wf-test", "")
+	_ = unstructured.SetNestedField(wf.UnstructuredContent(), "entry", "spec", "entrypoint")
+	_ = unstructured.SetNestedField(wf.UnstructuredContent(), "Failed", "status", "phase")
+
+	_, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Create(wf, metav1.CreateOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	phase, err := wfl.Retry(context.Background(), a, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+
+	retried, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get("addon-wf-test", metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(retried.UnstructuredContent()).To(Not(HaveKey("status")))
+	entrypoint, _, _ := unstructured.NestedString(retried.UnstructuredContent(), "spec", "entrypoint")
+	g.Expect(entrypoint).To(Equal("entry"))
+}
+
+// Test that the retry is only created once the failed workflow is gone
+func TestWorkflowLifecycle_Retry_WaitsForDeletion(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+		},
+	}
+
+	wf := newTestWorkflow("addon-wf-test", "")
+	wf.SetUID("old")
+	_ = unstructured.SetNestedField(wf.UnstructuredContent(), "entry", "spec", "entrypoint")
+	_ = unstructured.SetNestedField(wf.UnstructuredContent(), "Failed", "status", "phase")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch, wf.DeepCopy())
+
+	// The deleted workflow lingers for a few polls
+	var deleted, lingering int
+	dyn.PrependReactor("delete", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		deleted++
+		return false, nil, nil
+	})
+	dyn.PrependReactor("get", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if deleted == 0 || lingering == 2 {
+			return false, nil, nil
+		}
+		lingering++
+		return true, wf.DeepCopy(), nil
+	})
+
+	wfl := NewWorkflowLifecycleWithOptions(fclient, dyn, rcdr, sch, WithPollInterval(time.Millisecond))
+	phase, err := wfl.Retry(context.Background(), a, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	g.Expect(lingering).To(Equal(2))
+
+	var verbs []string
+	for _, action := range dyn.Actions() {
+		verbs = append(verbs, action.GetVerb())
+	}
+	g.Expect(verbs).To(Equal([]string{"get", "delete", "get", "get", "get", "create"}))
+
+	// The retry is not created if the workflow is never gone
+	dyn = dynfake.NewSimpleDynamicClient(sch, wf.DeepCopy())
+	dyn.PrependReactor("delete", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	wfl = NewWorkflowLifecycleWithOptions(fclient, dyn, rcdr, sch, WithPollInterval(time.Millisecond))
+	phase, err = wfl.Retry(ctx, a, "addon-wf-test")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+	g.Expect(phase).To(Equal(v1alpha1.Failed))
+	for _, action := range dyn.Actions() {
+		g.Expect(action.GetVerb()).To(Not(Equal("create")))
+	}
+}
+
+// Test that a running workflow is not retried
+func TestWorkflowLifecycle_Retry_Running(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+		},
+	}
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	wf := newTestWorkflow("addon-wf-test", "Running")
+
+	_, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Create(wf, metav1.CreateOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	phase, err := wfl.Retry(context.Background(), a, "addon-wf-test")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(phase).To(Equal(v1alpha1.Running))
+
+	running, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get("addon-wf-test", metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	status, _, _ := unstructured.NestedString(running.UnstructuredContent(), "status", "phase")
+	g.Expect(status).To(Equal("Running"))
+}
+
+func TestWorkflowLifecycle_Install_PkgParams(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+	a.Spec.PkgParams = map[string]string{"image-tag": "v1.2.3", "replicaCount": "3"}
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	phase, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	params, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "arguments", "parameters")
+	g.Expect(params).To(ContainElement(map[string]interface{}{"name": "image-tag", "value": "v1.2.3"}))
+	g.Expect(params).To(ContainElement(map[string]interface{}{"name": "replicaCount", "value": "3"}))
+}
+
+func TestWorkflowLifecycle_Install_ParamsFromConfigMap(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+	a.Spec.Params = v1alpha1.AddonParams{
+		Data: map[string]v1alpha1.FlexString{"replicaCount": "3"},
+	}
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo-params", Namespace: "default"},
+		Data:       map[string]string{"replicaCount": "5", "image-tag": "v1.2.3"},
+	}
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(runtimefake.NewFakeClientWithScheme(sch, cm), dyn, rcdr, sch)
+
+	wt := &v1alpha1.WorkflowType{Template: wfSpecTemplate, ParamsFromConfigMap: &v1alpha1.ConfigMapRef{Name: "foo-params"}}
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	// The addon params win over the config map
+	params, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "arguments", "parameters")
+	g.Expect(params).To(ContainElement(map[string]interface{}{"name": "image-tag", "value": "v1.2.3"}))
+	g.Expect(params).To(ContainElement(map[string]interface{}{"name": "replicaCount", "value": "3"}))
+	g.Expect(params).To(Not(ContainElement(map[string]interface{}{"name": "replicaCount", "value": "5"})))
+
+	// A missing config map fails the install
+	wt.ParamsFromConfigMap = &v1alpha1.ConfigMapRef{Namespace: "other", Name: "foo-params"}
+	phase, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-missing")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(HavePrefix("unable to get paramsFromConfigMap other/foo-params."))
+	g.Expect(phase).To(Equal(v1alpha1.Failed))
+
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get("addon-wf-missing", metav1.GetOptions{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
+
+// Test that a package param which is not a valid argo parameter name fails
+func TestWorkflowLifecycle_Install_InvalidPkgParams(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+	a.Spec.PkgParams = map[string]string{"image.tag": "v1.2.3"}
+
+	wfl := NewWorkflowLifecycle(fclient, dynfake.NewSimpleDynamicClient(sch), rcdr, sch)
+
+	phase, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(phase).To(Equal(v1alpha1.Failed))
+}
+
+func TestWorkflowLifecycle_Install_PkgVersion(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tests := []struct {
+		pkgVersion string
+		valid      bool
+	}{
+		{"1.0.0", true},
+		{"v2.3.1", true},
+		{"latest", false},
+	}
+
+	for _, tc := range tests {
+		a := newTestAddon("foo", "my-addon")
+		a.Spec.PkgVersion = tc.pkgVersion
+
+		wfl := NewWorkflowLifecycle(fclient, dynfake.NewSimpleDynamicClient(sch), rcdr, sch)
+
+		phase, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+		if tc.valid {
+			g.Expect(err).To(Not(HaveOccurred()), tc.pkgVersion)
+			g.Expect(phase).To(Equal(v1alpha1.Pending), tc.pkgVersion)
+		} else {
+			g.Expect(err).To(MatchError(ContainSubstring(`invalid pkgVersion "latest", must be a semantic version`)), tc.pkgVersion)
+			g.Expect(phase).To(Equal(v1alpha1.Failed), tc.pkgVersion)
+		}
+	}
+}
+
+func TestWorkflowLifecycle_CheckDependencies(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	installed := func(name, pkgName, pkgVersion string, phase v1alpha1.ApplicationAssemblyPhase) *v1alpha1.Addon {
+		return &v1alpha1.Addon{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "addon-manager-system"},
+			Spec: v1alpha1.AddonSpec{
+				PackageSpec: v1alpha1.PackageSpec{PkgName: pkgName, PkgVersion: pkgVersion, PkgType: v1alpha1.HelmPkg},
+			},
+			Status: v1alpha1.AddonStatus{Lifecycle: v1alpha1.AddonStatusLifecycle{Installed: phase}},
+		}
+	}
+
+	a := newTestAddon("foo", "my-addon")
+	a.Spec.PkgDeps = map[string]string{"core/A": "*", "core/B": "v1.0.0"}
+
+	tests := []struct {
+		name      string
+		installed []runtime.Object
+		satisfied bool
+		missing   []string
+	}{
+		{"none-installed", nil, false, []string{"core/A:*", "core/B:v1.0.0"}},
+		{"wrong-version", []runtime.Object{
+			installed("a", "core/A", "v0.1.0", v1alpha1.Succeeded),
+			installed("b", "core/B", "v0.9.0", v1alpha1.Succeeded),
+		}, false, []string{"core/B:v1.0.0"}},
+		{"not-succeeded", []runtime.Object{
+			installed("a", "core/A", "v0.1.0", v1alpha1.Succeeded),
+			installed("b", "core/B", "v1.0.0", v1alpha1.Failed),
+		}, false, []string{"core/B:v1.0.0"}},
+		{"satisfied", []runtime.Object{
+			installed("a", "core/A", "v0.1.0", v1alpha1.Succeeded),
+			installed("b", "core/B", "v1.0.0", v1alpha1.Succeeded),
+		}, true, nil},
+	}
+
+	for _, tc := range tests {
+		wfl := NewWorkflowLifecycle(runtimefake.NewFakeClientWithScheme(sch, tc.installed...), dynfake.NewSimpleDynamicClient(sch), rcdr, sch)
+
+		satisfied, missing, err := wfl.CheckDependencies(context.Background(), a)
+		g.Expect(err).To(Not(HaveOccurred()), tc.name)
+		g.Expect(satisfied).To(Equal(tc.satisfied), tc.name)
+		g.Expect(missing).To(Equal(tc.missing), tc.name)
+	}
+}
+
+// Test that install waits for dependencies when the workflow type asks for it
+func TestWorkflowLifecycle_Install_WaitForDependencies(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+	a.Spec.PkgDeps = map[string]string{"core/A": "*"}
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(runtimefake.NewFakeClientWithScheme(sch), dyn, rcdr, sch)
+
+	phase, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, WaitForDependencies: true}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get("addon-wf-test", metav1.GetOptions{})
+	g.Expect(err).To(HaveOccurred())
+
+	dep := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "default"},
+		Spec: v1alpha1.AddonSpec{
+			PackageSpec: v1alpha1.PackageSpec{PkgName: "core/A", PkgVersion: "v0.1.0", PkgType: v1alpha1.HelmPkg},
+		},
+		Status: v1alpha1.AddonStatus{Lifecycle: v1alpha1.AddonStatusLifecycle{Installed: v1alpha1.Succeeded}},
+	}
+	wfl = NewWorkflowLifecycle(runtimefake.NewFakeClientWithScheme(sch, dep), dyn, rcdr, sch)
+
+	phase, _, err = wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, WaitForDependencies: true}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get("addon-wf-test", metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+}
+
+func TestWorkflowLifecycle_Install_Preconditions(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	disc := &discoveryfake.FakeDiscovery{Fake: &clienttesting.Fake{Resources: []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "configmaps", Kind: "ConfigMap"}}},
+		{GroupVersion: "cert-manager.io/v1", APIResources: []metav1.APIResource{
+			{Name: "certificates", Kind: "Certificate"},
+			{Name: "issuers/status", Kind: "Issuer"},
+		}},
+	}}}
+	recorder := record.NewFakeRecorder(10)
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycleWithOptions(fclient, dyn, recorder, sch, WithDiscoveryClient(disc))
+
+	wt := &v1alpha1.WorkflowType{
+		Template: wfSpecTemplate,
+		Preconditions: []v1alpha1.APIPrecondition{
+			{Version: "v1", Kind: "ConfigMap"},
+			{Group: "cert-manager.io", Version: "v1", Kind: "Issuer"},
+			{Group: "cert-manager.io", Version: "v1alpha2", Kind: "Certificate"},
+		},
+	}
+	phase, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	g.Expect(<-recorder.Events).To(Equal("Normal PreconditionsUnmet Waiting on API resources cert-manager.io/v1/Issuer, cert-manager.io/v1alpha2/Certificate"))
+
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get("addon-wf-test", metav1.GetOptions{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+	// Waiting on the same preconditions isn't recorded again and reuses the discovered resources
+	discovered := len(disc.Actions())
+	phase, _, err = wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	g.Expect(recorder.Events).To(BeEmpty())
+	g.Expect(disc.Actions()).To(HaveLen(discovered))
+
+	// The resources are discovered again once they're stale
+	wfl.(*workflowLifecycle).discoveredAt = time.Now().Add(-2 * discoveryTTL)
+	phase, _, err = wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	g.Expect(len(disc.Actions())).To(BeNumerically(">", discovered))
+	g.Expect(recorder.Events).To(BeEmpty())
+
+	// The workflow is submitted once the preconditions are met
+	wt.Preconditions = []v1alpha1.APIPrecondition{
+		{Version: "v1", Kind: "ConfigMap"},
+		{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"},
+	}
+	phase, _, err = wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	g.Expect(<-recorder.Events).To(HavePrefix("Normal WorkflowSubmitted"))
+
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get("addon-wf-test", metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	// Preconditions can't be checked without a discovery client
+	wfl = NewWorkflowLifecycle(fclient, dyn, recorder, sch)
+	phase, _, err = wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-no-discovery")
+	g.Expect(err).To(MatchError("workflow preconditions require a discovery client"))
+	g.Expect(phase).To(Equal(v1alpha1.Failed))
+}
+
+func TestWorkflowLifecycle_Events(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	recorder := record.NewFakeRecorder(10)
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, recorder, sch)
+
+	_, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-events")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(<-recorder.Events).To(Equal("Normal WorkflowSubmitted Submitted workflow addon-wf-events for addon default/foo"))
+
+	g.Expect(wfl.Delete(context.Background(), a, "addon-wf-events")).To(Succeed())
+	g.Expect(<-recorder.Events).To(Equal("Normal WorkflowDeleted Deleted workflow addon-wf-events for addon default/foo"))
+
+	dyn.PrependReactor("create", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("apiserver unavailable")
+	})
+
+	_, _, err = wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-events")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(<-recorder.Events).To(HavePrefix("Warning WorkflowSubmitFailed Failed to submit workflow addon-wf-events for addon default/foo"))
+}
+
+func TestWorkflowLifecycle_InstallLifecycle(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tests := []struct {
+		name          string
+		prereqPhase   string
+		expectedPhase v1alpha1.ApplicationAssemblyPhase
+		installed     bool
+	}{
+		{"prereqs-succeeded", "Succeeded", v1alpha1.Pending, true},
+		{"prereqs-failed", "Failed", v1alpha1.Failed, false},
+	}
+
+	for _, tc := range tests {
+		a := newTestAddon("foo", "my-addon")
+		a.Spec.Lifecycle = v1alpha1.LifecycleWorkflowSpec{
+			Prereqs: v1alpha1.WorkflowType{Template: wfSpecTemplate},
+			Install: v1alpha1.WorkflowType{Template: wfSpecTemplate},
+		}
+
+		prereqName := a.GetFormattedWorkflowName(v1alpha1.Prereqs)
+		installName := a.GetFormattedWorkflowName(v1alpha1.Install)
+
+		// Argo picks up the submitted prereqs workflow and moves it to a completed phase
+		dyn := dynfake.NewSimpleDynamicClient(sch)
+		prereqPhase := tc.prereqPhase
+		dyn.PrependReactor("get", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			if action.(clienttesting.GetAction).GetName() != prereqName {
+				return false, nil, nil
+			}
+			wf := newTestWorkflow(prereqName, prereqPhase)
+			return true, wf, nil
+		})
+
+		wfl := NewWorkflowLifecycleWithOptions(fclient, dyn, rcdr, sch, WithPollInterval(time.Millisecond))
+
+		phase, err := wfl.InstallLifecycle(context.Background(), a, &a.Spec.Lifecycle.Prereqs, &a.Spec.Lifecycle.Install)
+		if tc.expectedPhase == v1alpha1.Failed {
+			g.Expect(err).To(HaveOccurred(), tc.name)
+		} else {
+			g.Expect(err).To(Not(HaveOccurred()), tc.name)
+		}
+		g.Expect(phase).To(Equal(tc.expectedPhase), tc.name)
+
+		_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(installName, metav1.GetOptions{})
+		g.Expect(err == nil).To(Equal(tc.installed), tc.name)
+	}
+}
+
+// Test that the install workflow is only submitted once the running prereqs workflow succeeds
+func TestWorkflowLifecycle_InstallLifecycle_Wait(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+	a.Spec.Lifecycle = v1alpha1.LifecycleWorkflowSpec{
+		Prereqs: v1alpha1.WorkflowType{Template: wfSpecTemplate},
+		Install: v1alpha1.WorkflowType{Template: wfSpecTemplate},
+	}
+
+	prereqName := a.GetFormattedWorkflowName(v1alpha1.Prereqs)
+	installName := a.GetFormattedWorkflowName(v1alpha1.Install)
+
+	// The prereqs workflow runs for a few polls before it succeeds, or never finishes
+	newClient := func(runningPolls int) (*dynfake.FakeDynamicClient, *int) {
+		var polls int
+		dyn := dynfake.NewSimpleDynamicClient(sch)
+		dyn.PrependReactor("get", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			if action.(clienttesting.GetAction).GetName() != prereqName {
+				return false, nil, nil
+			}
+			polls++
+			phase := "Running"
+			if runningPolls >= 0 && polls > runningPolls {
+				phase = "Succeeded"
+			}
+			wf := newTestWorkflow(prereqName, phase)
+			return true, wf, nil
+		})
+		return dyn, &polls
+	}
+
+	dyn, polls := newClient(3)
+	wfl := NewWorkflowLifecycleWithOptions(fclient, dyn, rcdr, sch, WithPollInterval(time.Millisecond))
+	phase, err := wfl.InstallLifecycle(context.Background(), a, &a.Spec.Lifecycle.Prereqs, &a.Spec.Lifecycle.Install)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	g.Expect(*polls).To(BeNumerically(">", 3))
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(installName, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	// The install workflow is not submitted once the context is done
+	dyn, _ = newClient(-1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	wfl = NewWorkflowLifecycleWithOptions(fclient, dyn, rcdr, sch, WithPollInterval(time.Millisecond))
+	phase, err = wfl.InstallLifecycle(ctx, a, &a.Spec.Lifecycle.Prereqs, &a.Spec.Lifecycle.Install)
+	g.Expect(err).To(MatchError(context.DeadlineExceeded))
+	g.Expect(phase).To(Equal(v1alpha1.Failed))
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(installName, metav1.GetOptions{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+	// A prereqs workflow waiting on dependencies is neither submitted nor waited on
+	gated := a.DeepCopy()
+	gated.Spec.PkgDeps = map[string]string{"core/missing": "*"}
+	gated.Spec.Lifecycle.Prereqs.WaitForDependencies = true
+	dyn = dynfake.NewSimpleDynamicClient(sch)
+	wfl = NewWorkflowLifecycleWithOptions(fclient, dyn, rcdr, sch, WithPollInterval(time.Millisecond))
+	phase, err = wfl.InstallLifecycle(context.Background(), gated, &gated.Spec.Lifecycle.Prereqs, &gated.Spec.Lifecycle.Install)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	for _, action := range dyn.Actions() {
+		g.Expect(action.GetVerb()).To(Not(Equal("create")))
+	}
+}
+
+func TestWorkflowLifecycle_ChecksumInstall(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+	a.Spec.Params = v1alpha1.AddonParams{
+		Data: map[string]v1alpha1.FlexString{"a": "1", "b": "2", "c": "3"},
+	}
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	created := 0
+	dyn.PrependReactor("create", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		obj := action.(clienttesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		created++
+		obj.SetName(fmt.Sprintf("%s%d", obj.GetGenerateName(), created))
+		return false, nil, nil
+	})
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	wt := &v1alpha1.WorkflowType{Template: wfSpecTemplate, Role: "arn:aws:iam::123456789012:role/addon"}
+	checksum, err := wfl.ChecksumInstall(context.Background(), a, v1alpha1.Install, wt)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(checksum).To(Not(BeEmpty()))
+	g.Expect(wfl.ChecksumInstall(context.Background(), a, v1alpha1.Install, wt)).To(Equal(checksum))
+
+	// A workflow that can't be rendered has no checksum
+	_, err = wfl.ChecksumInstall(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: "invalid"})
+	g.Expect(err).To(HaveOccurred())
+
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(wf.GetAnnotations()).To(HaveKeyWithValue(WorkflowChecksumAnnotation, checksum))
+
+	// Identical inputs find the submitted workflow
+	_, again, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(again).To(Equal(name))
+	g.Expect(created).To(Equal(1))
+
+	// Changed inputs submit a new workflow
+	changed := &v1alpha1.WorkflowType{Template: wfSpecTemplate, Role: "arn:aws:iam::123456789012:role/other"}
+	g.Expect(wfl.ChecksumInstall(context.Background(), a, v1alpha1.Install, changed)).To(Not(Equal(checksum)))
+
+	_, other, err := wfl.Install(context.Background(), a, v1alpha1.Install, changed, "")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(other).To(Not(Equal(name)))
+	g.Expect(created).To(Equal(2))
+}
+
+// Test that a named workflow is only replaced when its checksum changed
+func TestWorkflowLifecycle_Install_NamedChecksum(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	wt := &v1alpha1.WorkflowType{Template: wfSpecTemplate, Role: "arn:aws:iam::123456789012:role/addon"}
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(name).To(Equal("addon-wf-test"))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	checksum := wf.GetAnnotations()[WorkflowChecksumAnnotation]
+	g.Expect(checksum).To(Not(BeEmpty()))
+
+	verbs := func() []string {
+		var verbs []string
+		for _, action := range dyn.Actions() {
+			verbs = append(verbs, action.GetVerb())
+		}
+		return verbs
+	}
+
+	// Identical inputs keep the submitted workflow
+	dyn.ClearActions()
+	_, _, err = wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(verbs()).To(Not(ContainElement("create")))
+
+	// Changed inputs replace it under the same name
+	changed := &v1alpha1.WorkflowType{Template: wfSpecTemplate, Role: "arn:aws:iam::123456789012:role/other"}
+	dyn.ClearActions()
+	phase, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, changed, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	g.Expect(name).To(Equal("addon-wf-test"))
+	g.Expect(verbs()).To(ContainElement("delete"))
+	g.Expect(verbs()).To(ContainElement("create"))
+
+	wf, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(wf.GetAnnotations()[WorkflowChecksumAnnotation]).To(Not(Equal(checksum)))
+
+	// A workflow submitted before it carried a checksum is kept
+	annotations := wf.GetAnnotations()
+	delete(annotations, WorkflowChecksumAnnotation)
+	wf.SetAnnotations(annotations)
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Update(wf, metav1.UpdateOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	dyn.ClearActions()
+	_, _, err = wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(verbs()).To(Not(ContainElement("delete")))
+}
+
+func TestWorkflowLifecycle_Install_IdempotencyKey(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	created := 0
+	dyn.PrependReactor("create", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		obj := action.(clienttesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		created++
+		obj.SetName(fmt.Sprintf("%s%d", obj.GetGenerateName(), created))
+		return false, nil, nil
+	})
+
+	// Each controller replica reconciles with its own lifecycle
+	wt := &v1alpha1.WorkflowType{Template: wfSpecTemplate}
+	_, name, err := NewWorkflowLifecycle(fclient, dyn, rcdr, sch).Install(context.Background(), a, v1alpha1.Install, wt, "")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	key := wf.GetAnnotations()[WorkflowIdempotencyKeyAnnotation]
+	g.Expect(key).To(Not(BeEmpty()))
+
+	phase, again, err := NewWorkflowLifecycle(fclient, dyn, rcdr, sch).Install(context.Background(), a, v1alpha1.Install, wt, "")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	g.Expect(again).To(Equal(name))
+	g.Expect(created).To(Equal(1))
+
+	// An addon recreated under the same name doesn't adopt the workflows of the old one
+	recreated := a.DeepCopy()
+	recreated.SetUID("7d1c4b0e-5f3a-4c2e-9b8d-0e6f1a2b3c4d")
+	_, other, err := NewWorkflowLifecycle(fclient, dyn, rcdr, sch).Install(context.Background(), recreated, v1alpha1.Install, wt, "")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(other).To(Not(Equal(name)))
+	g.Expect(created).To(Equal(2))
+
+	wf, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(other, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(wf.GetAnnotations()[WorkflowIdempotencyKeyAnnotation]).To(Not(Equal(key)))
+
+	// Another step running the same template doesn't adopt the install workflow
+	_, prereqs, err := NewWorkflowLifecycle(fclient, dyn, rcdr, sch).Install(context.Background(), a, v1alpha1.Prereqs, wt, "")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(prereqs).To(Not(Equal(name)))
+	g.Expect(created).To(Equal(3))
+
+	wf, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(prereqs, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(wf.GetLabels()).To(HaveKeyWithValue(WorkflowStepLabel, string(v1alpha1.Prereqs)))
+	g.Expect(wf.GetAnnotations()[WorkflowIdempotencyKeyAnnotation]).To(Not(Equal(key)))
+
+	_, again, err = NewWorkflowLifecycle(fclient, dyn, rcdr, sch).Install(context.Background(), a, v1alpha1.Prereqs, wt, "")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(again).To(Equal(prereqs))
+	g.Expect(created).To(Equal(3))
+}
+
+func TestWorkflowLifecycle_Install_TemplateRef(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	phase, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{TemplateRef: "default/my-addon-install"}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	ref, _, _ := unstructured.NestedString(wf.UnstructuredContent(), "spec", "workflowTemplateRef", "name")
+	g.Expect(ref).To(Equal("my-addon-install"))
+	_, found, _ := unstructured.NestedFieldNoCopy(wf.UnstructuredContent(), "spec", "templates")
+	g.Expect(found).To(BeFalse())
+}
+
+// Test that exactly one of template or templateRef must be given
+func TestWorkflowLifecycle_Install_InvalidTemplateRef(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	wfl := NewWorkflowLifecycle(fclient, dynfake.NewSimpleDynamicClient(sch), rcdr, sch)
+
+	tests := []struct {
+		name string
+		wt   *v1alpha1.WorkflowType
+	}{
+		{"both", &v1alpha1.WorkflowType{Template: wfSpecTemplate, TemplateRef: "my-addon-install"}},
+		{"neither", &v1alpha1.WorkflowType{}},
+		{"other-namespace", &v1alpha1.WorkflowType{TemplateRef: "argo/my-addon-install"}},
+	}
+
+	for _, tc := range tests {
+		phase, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, tc.wt, "addon-wf-test")
+		g.Expect(err).To(HaveOccurred(), tc.name)
+		g.Expect(phase).To(Equal(v1alpha1.Failed), tc.name)
+	}
+}
+
+func TestWorkflowLifecycle_Install_ActiveDeadline(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	// Default deadline is used when none is given
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-default-deadline")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	deadline, found, _ := unstructured.NestedInt64(wf.UnstructuredContent(), "spec", "activeDeadlineSeconds")
+	g.Expect(found).To(BeTrue())
+	g.Expect(deadline).To(Equal(defaultActiveDeadlineSeconds))
+
+	// Configured deadline overrides the default
+	var seconds int64 = 300
+	_, name, err = wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, ActiveDeadlineSeconds: &seconds}, "addon-wf-deadline")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	deadline, found, _ = unstructured.NestedInt64(wf.UnstructuredContent(), "spec", "activeDeadlineSeconds")
+	g.Expect(found).To(BeTrue())
+	g.Expect(deadline).To(Equal(int64(300)))
+}
+
+func TestWorkflowLifecycle_WaitForCompletion(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	// Each poll observes the next phase of the workflow
+	phases := []string{"Pending", "Running", "Running", "Succeeded"}
+	polls := 0
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	dyn.PrependReactor("get", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		wf := newTestWorkflow("addon-wf-test", phases[polls])
+		if polls < len(phases)-1 {
+			polls++
+		}
+		return true, wf, nil
+	})
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	phase, err := wfl.WaitForCompletion(context.Background(), a, "addon-wf-test", time.Millisecond)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Succeeded))
+	g.Expect(polls).To(Equal(len(phases) - 1))
+
+	// A workflow that never completes returns the last phase once the context is done
+	phases = []string{"Running"}
+	polls = 0
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	phase, err = wfl.WaitForCompletion(ctx, a, "addon-wf-test", time.Millisecond)
+	g.Expect(err).To(Equal(context.DeadlineExceeded))
+	g.Expect(phase).To(Equal(v1alpha1.Running))
+}
+
+func TestPollBackoff(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	backoff := pollBackoff(10 * time.Second)
+	backoff.Jitter = 0
+	g.Expect(backoff.Step()).To(Equal(10 * time.Second))
+	g.Expect(backoff.Step()).To(Equal(15 * time.Second))
+	for i := 0; i < 10; i++ {
+		backoff.Step()
+	}
+	g.Expect(backoff.Step()).To(Equal(maxPollInterval))
+
+	// An unset interval starts from the default
+	g.Expect(pollBackoff(0).Duration).To(Equal(defaultPollInterval))
+}
+
+func TestWorkflowLifecycle_OnPhaseChange(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	phases := []string{"Running", "Running", "Failed"}
+	polls := 0
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	dyn.PrependReactor("get", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		wf := newTestWorkflow("addon-wf-test", phases[polls])
+		if polls < len(phases)-1 {
+			polls++
+		}
+		return true, wf, nil
+	})
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	type transition struct {
+		name     string
+		from, to v1alpha1.ApplicationAssemblyPhase
+	}
+	var first, second []transition
+	wfl.OnPhaseChange(func(addon *v1alpha1.Addon, name string, from, to v1alpha1.ApplicationAssemblyPhase) {
+		g.Expect(addon.Name).To(Equal("foo"))
+		first = append(first, transition{name, from, to})
+	})
+	wfl.OnPhaseChange(func(addon *v1alpha1.Addon, name string, from, to v1alpha1.ApplicationAssemblyPhase) {
+		second = append(second, transition{name, from, to})
+	})
+
+	phase, err := wfl.WaitForCompletion(context.Background(), a, "addon-wf-test", time.Millisecond)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Failed))
+
+	expected := []transition{
+		{"addon-wf-test", "", v1alpha1.Running},
+		{"addon-wf-test", v1alpha1.Running, v1alpha1.Failed},
+	}
+	g.Expect(first).To(Equal(expected))
+	g.Expect(second).To(Equal(expected))
+}
+
+func TestWorkflowLifecycle_WorkflowNamespace(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "addons",
+			UID:       "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5",
+		},
+		Spec: v1alpha1.AddonSpec{
+			PackageSpec: v1alpha1.PackageSpec{
+				PkgName:        "my-addon",
+				PkgVersion:     "1.0.0",
+				PkgType:        v1alpha1.HelmPkg,
+				PkgDescription: "",
+			},
+		},
+	}
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycleWithOptions(fclient, dyn, rcdr, sch, WithWorkflowNamespace("addon-workflows"))
+
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("addon-workflows").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(wf.GetOwnerReferences()).To(BeEmpty())
+	g.Expect(wf.GetAnnotations()).To(HaveKeyWithValue(WorkflowSourceAnnotation, "addons/foo"))
+
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("addons").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(HaveOccurred())
+
+	phase, err := wfl.GetStatus(context.Background(), a, name)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+
+	g.Expect(wfl.DeleteStrict(context.Background(), a, name)).To(Succeed())
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("addon-workflows").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestNewWorkflowLifecycleWithOptions(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	// Defaults are used when no options are given
+	wfl := NewWorkflowLifecycleWithOptions(fclient, dynClient, rcdr, sch).(*workflowLifecycle)
+	g.Expect(wfl.bind(a).workflowNamespace()).To(Equal("default"))
+	g.Expect(wfl.defaultTTL).To(Equal(defaultTTLSecondsAfterCompletion))
+	g.Expect(wfl.activeDeadline).To(Equal(defaultActiveDeadlineSeconds))
+	g.Expect(wfl.pollInterval).To(Equal(defaultPollInterval))
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	opts := NewWorkflowLifecycleWithOptions(fclient, dyn, rcdr, sch, WithWorkflowNamespace("addon-workflows"), WithDefaultTTL(60), WithActiveDeadline(120))
+
+	_, name, err := opts.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("addon-workflows").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	ttl, _, _ := unstructured.NestedInt64(wf.UnstructuredContent(), "spec", "ttlStrategy", "secondsAfterCompletion")
+	g.Expect(ttl).To(Equal(int64(60)))
+	deadline, _, _ := unstructured.NestedInt64(wf.UnstructuredContent(), "spec", "activeDeadlineSeconds")
+	g.Expect(deadline).To(Equal(int64(120)))
+}
+
+func TestWorkflowLifecycle_DryRunInstall(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+	a.Spec.Params = v1alpha1.AddonParams{
+		Namespace: "foo-ns",
+	}
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	wf, err := wfl.DryRunInstall(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(wf.GetName()).To(Equal("addon-wf-test"))
+	g.Expect(wf.GetLabels()).To(HaveKeyWithValue(WorkflowAddonLabel, "foo"))
+	g.Expect(wf.GetLabels()).To(HaveKeyWithValue(WorkflowStepLabel, "install"))
+
+	params, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "arguments", "parameters")
+	g.Expect(params).To(ContainElement(map[string]interface{}{"name": "namespace", "value": "foo-ns"}))
+
+	// Nothing is persisted
+	list, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").List(metav1.ListOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(list.Items).To(BeEmpty())
+
+	_, err = wfl.DryRunInstall(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: "kind: Pod"}, "addon-wf-test")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestWorkflowLifecycle_DetectDrift(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	wt := &v1alpha1.WorkflowType{Template: wfSpecTemplate}
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	drifted, diff, err := wfl.DetectDrift(context.Background(), a, v1alpha1.Install, wt, name)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(drifted).To(BeFalse())
+	g.Expect(diff).To(BeEmpty())
+
+	// Change the image of the live workflow
+	workflows := dyn.Resource(common.WorkflowGVR()).Namespace("default")
+	wf, err := workflows.Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	templates, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "templates")
+	for _, template := range templates {
+		if script, ok := template.(map[string]interface{})["script"].(map[string]interface{}); ok && script["image"] == "python:alpine3.6" {
+			script["image"] = "python:3.9"
+		}
+	}
+	g.Expect(unstructured.SetNestedSlice(wf.UnstructuredContent(), templates, "spec", "templates")).To(Succeed())
+	g.Expect(unstructured.SetNestedField(wf.UnstructuredContent(), true, "spec", "suspend")).To(Succeed())
+	_, err = workflows.Update(wf, metav1.UpdateOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	drifted, diff, err = wfl.DetectDrift(context.Background(), a, v1alpha1.Install, wt, name)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(drifted).To(BeTrue())
+	g.Expect(diff).To(ContainSubstring("python:3.9"))
+	g.Expect(diff).To(ContainSubstring("python:alpine3.6"))
+	g.Expect(diff).To(Not(ContainSubstring("suspend")))
+
+	_, _, err = wfl.DetectDrift(context.Background(), a, v1alpha1.Install, wt, "addon-wf-missing")
+	g.Expect(errors.Is(err, ErrWorkflowNotFound)).To(BeTrue())
+}
+
+func TestWorkflowLifecycle_PlanInstall(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+	a.Spec.Params = v1alpha1.AddonParams{
+		Data: map[string]v1alpha1.FlexString{"replicas": "1"},
+	}
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+	wt := &v1alpha1.WorkflowType{Template: wfSpecTemplate}
+
+	action, err := wfl.PlanInstall(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(action).To(Equal(InstallActionCreate))
+	for _, action := range dyn.Actions() {
+		g.Expect(action.GetVerb()).To(Or(Equal("get"), Equal("list")))
+	}
+
+	_, _, err = wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	action, err = wfl.PlanInstall(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(action).To(Equal(InstallActionAdopt))
+
+	// The addon changed since the workflow was submitted
+	changed := a.DeepCopy()
+	changed.Spec.Params.Data["replicas"] = "3"
+	wfl = NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	action, err = wfl.PlanInstall(context.Background(), changed, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(action).To(Equal(InstallActionCreate))
+
+	// Workflows submitted before they carried a checksum are left as is
+	live, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get("addon-wf-test", metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	live.SetAnnotations(nil)
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Update(live, metav1.UpdateOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	action, err = wfl.PlanInstall(context.Background(), changed, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(action).To(Equal(InstallActionSkip))
+
+	_, err = wfl.PlanInstall(context.Background(), changed, v1alpha1.Install, &v1alpha1.WorkflowType{Template: "kind: Pod"}, "addon-wf-test")
+	g.Expect(err).To(HaveOccurred())
+
+	// Install waits on the missing dependencies without submitting
+	dependent := a.DeepCopy()
+	dependent.Spec.PkgDeps = map[string]string{"core/A": "*"}
+	waiting := &v1alpha1.WorkflowType{Template: wfSpecTemplate, WaitForDependencies: true}
+	action, err = wfl.PlanInstall(context.Background(), dependent, v1alpha1.Install, waiting, "addon-wf-waiting")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(action).To(Equal(InstallActionWait))
+
+	phase, _, err := wfl.Install(context.Background(), dependent, v1alpha1.Install, waiting, "addon-wf-waiting")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get("addon-wf-waiting", metav1.GetOptions{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
+
+func TestWorkflowLifecycle_CheckDependencies_Cycle(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	addon := func(name, pkgName string, deps map[string]string) *v1alpha1.Addon {
+		return &v1alpha1.Addon{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5"},
+			Spec: v1alpha1.AddonSpec{
+				PackageSpec: v1alpha1.PackageSpec{PkgName: pkgName, PkgVersion: "v1.0.0", PkgType: v1alpha1.HelmPkg, PkgDeps: deps},
+			},
+			Status: v1alpha1.AddonStatus{Lifecycle: v1alpha1.AddonStatusLifecycle{Installed: v1alpha1.Succeeded}},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		addon     *v1alpha1.Addon
+		installed []runtime.Object
+		cycle     []string
+	}{
+		{"two-nodes", addon("a", "core/A", map[string]string{"core/B": "*"}), []runtime.Object{
+			addon("b", "core/B", map[string]string{"core/A": "*"}),
+		}, []string{"core/A", "core/B", "core/A"}},
+		{"three-nodes", addon("a", "core/A", map[string]string{"core/B": "*"}), []runtime.Object{
+			addon("b", "core/B", map[string]string{"core/C": "*"}),
+			addon("c", "core/C", map[string]string{"core/A": "v1.0.0"}),
+		}, []string{"core/A", "core/B", "core/C", "core/A"}},
+	}
+
+	for _, tc := range tests {
+		wfl := NewWorkflowLifecycle(runtimefake.NewFakeClientWithScheme(sch, tc.installed...), dynfake.NewSimpleDynamicClient(sch), rcdr, sch)
+
+		satisfied, _, err := wfl.CheckDependencies(context.Background(), tc.addon)
+		g.Expect(satisfied).To(BeFalse(), tc.name)
+		g.Expect(err).To(BeAssignableToTypeOf(&ErrDependencyCycle{}), tc.name)
+		g.Expect(errors.Is(err, ErrDependenciesUnsatisfied)).To(BeTrue(), tc.name)
+		g.Expect(err.(*ErrDependencyCycle).Cycle).To(Equal(tc.cycle), tc.name)
+		for _, pkgName := range tc.cycle {
+			g.Expect(err.Error()).To(ContainSubstring(pkgName), tc.name)
+		}
+
+		// Install refuses to wait on dependencies that can never be satisfied
+		phase, _, err := wfl.Install(context.Background(), tc.addon, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, WaitForDependencies: true}, "addon-wf-test")
+		g.Expect(errors.Is(err, ErrDependenciesUnsatisfied)).To(BeTrue(), tc.name)
+		g.Expect(phase).To(Equal(v1alpha1.Failed), tc.name)
+	}
+}
+
+func TestWorkflowLifecycle_TransitiveDependencies(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	addon := func(name, pkgName string, deps map[string]string) *v1alpha1.Addon {
+		return &v1alpha1.Addon{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5"},
+			Spec: v1alpha1.AddonSpec{
+				PackageSpec: v1alpha1.PackageSpec{PkgName: pkgName, PkgVersion: "v1.0.0", PkgType: v1alpha1.HelmPkg, PkgDeps: deps},
+			},
+		}
+	}
+
+	// core/A -> core/B -> core/C -> core/D, core/A -> core/E which is not installed and core/F is unrelated
+	installed := []runtime.Object{
+		addon("b", "core/B", map[string]string{"core/C": "*"}),
+		addon("c", "core/C", map[string]string{"core/D": "*"}),
+		addon("d", "core/D", nil),
+		addon("f", "core/F", map[string]string{"core/A": "*"}),
+	}
+	a := addon("a", "core/A", map[string]string{"core/E": "*", "core/B": "*"})
+	wfl := NewWorkflowLifecycle(runtimefake.NewFakeClientWithScheme(sch, installed...), dynfake.NewSimpleDynamicClient(sch), rcdr, sch)
+
+	deps, err := wfl.TransitiveDependencies(context.Background(), a)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(deps).To(Equal([]string{"core/B", "core/C", "core/D", "core/E"}))
+
+	// A cycle that is not reachable from the addon is ignored
+	a = addon("a", "core/A", map[string]string{"core/D": "*"})
+	installed = []runtime.Object{
+		addon("b", "core/B", map[string]string{"core/C": "*"}),
+		addon("c", "core/C", map[string]string{"core/B": "*"}),
+		addon("d", "core/D", map[string]string{"core/G": "*"}),
+		addon("g", "core/G", nil),
+	}
+	wfl = NewWorkflowLifecycle(runtimefake.NewFakeClientWithScheme(sch, installed...), dynfake.NewSimpleDynamicClient(sch), rcdr, sch)
+
+	deps, err = wfl.TransitiveDependencies(context.Background(), a)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(deps).To(Equal([]string{"core/D", "core/G"}))
+
+	// A cycle reached from the addon is an error
+	a = addon("a", "core/A", map[string]string{"core/B": "*"})
+	wfl = NewWorkflowLifecycle(runtimefake.NewFakeClientWithScheme(sch, installed...), dynfake.NewSimpleDynamicClient(sch), rcdr, sch)
+
+	deps, err = wfl.TransitiveDependencies(context.Background(), a)
+	g.Expect(err).To(BeAssignableToTypeOf(&ErrDependencyCycle{}))
+	g.Expect(err.(*ErrDependencyCycle).Cycle).To(Equal([]string{"core/B", "core/C", "core/B"}))
+	g.Expect(deps).To(BeNil())
+
+	// No package dependencies
+	wfl = NewWorkflowLifecycle(fclient, dynfake.NewSimpleDynamicClient(sch), rcdr, sch)
+	deps, err = wfl.TransitiveDependencies(context.Background(), addon("a", "core/A", nil))
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(deps).To(BeEmpty())
+}
+
+// Test that dependency ranges are matched and invalid constraints fail the install
+func TestWorkflowLifecycle_CheckDependencies_Constraints(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+	a.Spec.PkgDeps = map[string]string{"core/A": ">=1.2.0 <2.0.0"}
+
+	dep := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "default"},
+		Spec: v1alpha1.AddonSpec{
+			PackageSpec: v1alpha1.PackageSpec{PkgName: "core/A", PkgVersion: "v1.4.0", PkgType: v1alpha1.HelmPkg},
+		},
+		Status: v1alpha1.AddonStatus{Lifecycle: v1alpha1.AddonStatusLifecycle{Installed: v1alpha1.Succeeded}},
+	}
+
+	wfl := NewWorkflowLifecycle(runtimefake.NewFakeClientWithScheme(sch, dep), dynfake.NewSimpleDynamicClient(sch), rcdr, sch)
+
+	satisfied, missing, err := wfl.CheckDependencies(context.Background(), a)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(satisfied).To(BeTrue())
+	g.Expect(missing).To(BeEmpty())
+
+	a.Spec.PkgDeps = map[string]string{"core/A": ">=1.2.0 <<2"}
+	wfl = NewWorkflowLifecycle(runtimefake.NewFakeClientWithScheme(sch, dep), dynfake.NewSimpleDynamicClient(sch), rcdr, sch)
+
+	_, _, err = wfl.CheckDependencies(context.Background(), a)
+	g.Expect(errors.Is(err, ErrDependenciesUnsatisfied)).To(BeTrue())
+
+	phase, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(errors.Is(err, ErrDependenciesUnsatisfied)).To(BeTrue())
+	g.Expect(phase).To(Equal(v1alpha1.Failed))
+}
+
+func TestWorkflowLifecycle_DependencyReport(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+	a.Spec.PkgDeps = map[string]string{"core/A": "^1.2", "core/B": ">=2.0.0", "core/C": "*"}
+
+	installed := func(name, pkgName, pkgVersion string) *v1alpha1.Addon {
+		return &v1alpha1.Addon{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: v1alpha1.AddonSpec{
+				PackageSpec: v1alpha1.PackageSpec{PkgName: pkgName, PkgVersion: pkgVersion, PkgType: v1alpha1.HelmPkg},
+			},
+			Status: v1alpha1.AddonStatus{Lifecycle: v1alpha1.AddonStatusLifecycle{Installed: v1alpha1.Succeeded}},
+		}
+	}
+
+	wfl := NewWorkflowLifecycle(runtimefake.NewFakeClientWithScheme(sch, installed("a", "core/A", "v1.4.0"), installed("b", "core/B", "v1.9.0")), dynfake.NewSimpleDynamicClient(sch), rcdr, sch)
+
+	report, err := wfl.DependencyReport(context.Background(), a)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(report).To(Equal([]DependencyStatus{
+		{PkgName: "core/A", Constraint: "^1.2", Satisfied: true, InstalledVersion: "v1.4.0"},
+		{PkgName: "core/B", Constraint: ">=2.0.0", Satisfied: false, InstalledVersion: "v1.9.0"},
+		{PkgName: "core/C", Constraint: "*", Satisfied: false},
+	}))
+}
+
+func TestWorkflowLifecycle_LastWorkflow(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	wfl := NewWorkflowLifecycle(fclient, dynfake.NewSimpleDynamicClient(sch), rcdr, sch)
+
+	name, phase := wfl.LastWorkflow(a)
+	g.Expect(name).To(BeEmpty())
+	g.Expect(phase).To(BeEmpty())
+
+	_, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	name, phase = wfl.LastWorkflow(a)
+	g.Expect(name).To(Equal("addon-wf-test"))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+}
+
+func TestWorkflowLifecycle_Install_ImagePullSecrets(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	wt := &v1alpha1.WorkflowType{
+		Template:         wfSpecTemplate,
+		ImagePullSecrets: []string{"registry-creds", "", "mirror-creds", "registry-creds"},
+	}
+
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	secrets, found, err := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "imagePullSecrets")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(found).To(BeTrue())
+	g.Expect(secrets).To(Equal([]interface{}{
+		map[string]interface{}{"name": "registry-creds"},
+		map[string]interface{}{"name": "mirror-creds"},
+	}))
+}
+
+func TestWorkflowLifecycle_Install_RetryStrategy(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	wt := &v1alpha1.WorkflowType{
+		Template: wfSpecTemplate,
+		RetryStrategy: &v1alpha1.RetryStrategy{
+			Limit:       3,
+			RetryPolicy: "OnFailure",
+			Backoff:     &v1alpha1.RetryBackoff{Duration: "10s", Factor: 2, MaxDuration: "5m"},
+		},
+	}
+
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	templates, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "templates")
+	g.Expect(templates).To(HaveLen(3))
+	for _, template := range templates {
+		template := template.(map[string]interface{})
+		if template["steps"] != nil {
+			g.Expect(template).To(Not(HaveKey("retryStrategy")))
+			continue
+		}
+		g.Expect(template["retryStrategy"]).To(Equal(map[string]interface{}{
+			"limit":       int64(3),
+			"retryPolicy": "OnFailure",
+			"backoff":     map[string]interface{}{"duration": "10s", "factor": int64(2), "maxDuration": "5m"},
+		}))
+	}
+
+	// No retry strategy is injected when none is configured
+	_, name, err = wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-no-retry")
+	g.Expect(err).To(Not(HaveOccurred()))
+	wf, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	templates, _, _ = unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "templates")
+	for _, template := range templates {
+		g.Expect(template).To(Not(HaveKey("retryStrategy")))
+	}
+
+	// Negative limits are rejected
+	phase, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, RetryStrategy: &v1alpha1.RetryStrategy{Limit: -1}}, "addon-wf-bad-retry")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(phase).To(Equal(v1alpha1.Failed))
+}
+
+func TestWorkflowLifecycle_ListWorkflows(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	workflow := func(name, addon string, step v1alpha1.LifecycleStep, phase string, created time.Time) runtime.Object {
+		wf := newTestWorkflow(name, "")
+		wf.SetLabels(map[string]string{WorkflowAddonLabel: addon, WorkflowStepLabel: string(step)})
+		wf.SetCreationTimestamp(metav1.NewTime(created))
+		_ = unstructured.SetNestedField(wf.Object, phase, "status", "phase")
+		return wf
+	}
+
+	now := time.Now().Truncate(time.Second)
+	dyn := dynfake.NewSimpleDynamicClient(sch,
+		workflow("foo-prereqs-1234abcd-wf", "foo", v1alpha1.Prereqs, "Succeeded", now.Add(-time.Hour)),
+		workflow("foo-install-1234abcd-wf", "foo", v1alpha1.Install, "Running", now),
+		workflow("bar-install-1234abcd-wf", "bar", v1alpha1.Install, "Running", now),
+	)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	infos, err := wfl.ListWorkflows(context.Background(), a)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(infos).To(Equal([]WorkflowInfo{
+		{Name: "foo-install-1234abcd-wf", Phase: v1alpha1.Running, Type: v1alpha1.Install, CreatedAt: metav1.NewTime(now)},
+		{Name: "foo-prereqs-1234abcd-wf", Phase: v1alpha1.Succeeded, Type: v1alpha1.Prereqs, CreatedAt: metav1.NewTime(now.Add(-time.Hour))},
+	}))
+}
+
+func TestWorkflowLifecycle_ListByLabelSelector(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+			UID:       "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5",
+		},
+	}
+
+	workflow := func(namespace, name, phase string, lbls map[string]string, created time.Time) runtime.Object {
+		wf := &unstructured.Unstructured{}
+		wf.SetAPIVersion("argoproj.io/v1alpha1")
+		wf.SetKind("Workflow")
+		wf.SetNamespace(namespace)
+		wf.SetName(name)
+		wf.SetLabels(lbls)
+		wf.SetCreationTimestamp(metav1.NewTime(created))
+		_ = unstructured.SetNestedField(wf.Object, phase, "status", "phase")
+		return wf
+	}
+
+	now := time.Now().Truncate(time.Second)
+	dyn := dynfake.NewSimpleDynamicClient(sch,
+		workflow("default", "foo-install-1234abcd-wf", "Succeeded", map[string]string{WorkflowAddonLabel: "foo", WorkflowStepLabel: "install", "team": "core"}, now.Add(-time.Hour)),
+		workflow("default", "bar-install-1234abcd-wf", "Running", map[string]string{WorkflowAddonLabel: "bar", WorkflowStepLabel: "install", "team": "core"}, now),
+		workflow("default", "baz-delete-1234abcd-wf", "Failed", map[string]string{WorkflowAddonLabel: "baz", WorkflowStepLabel: "delete", "team": "edge"}, now),
+		workflow("other", "qux-install-1234abcd-wf", "Running", map[string]string{WorkflowAddonLabel: "qux", WorkflowStepLabel: "install", "team": "core"}, now),
+	)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	selector, err := labels.Parse("team=core")
+	g.Expect(err).To(Not(HaveOccurred()))
+	infos, err := wfl.ListByLabelSelector(context.Background(), a, selector)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(infos).To(Equal([]WorkflowInfo{
+		{Name: "bar-install-1234abcd-wf", Phase: v1alpha1.Running, Type: v1alpha1.Install, CreatedAt: metav1.NewTime(now)},
+		{Name: "foo-install-1234abcd-wf", Phase: v1alpha1.Succeeded, Type: v1alpha1.Install, CreatedAt: metav1.NewTime(now.Add(-time.Hour))},
+	}))
+
+	selector, err = labels.Parse(WorkflowAddonLabel + " in (baz,qux)")
+	g.Expect(err).To(Not(HaveOccurred()))
+	infos, err = wfl.ListByLabelSelector(context.Background(), a, selector)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(infos).To(Equal([]WorkflowInfo{
+		{Name: "baz-delete-1234abcd-wf", Phase: v1alpha1.Failed, Type: v1alpha1.Delete, CreatedAt: metav1.NewTime(now)},
+	}))
+
+	// Workflows of a lifecycle step are selected by the step label
+	infos, err = wfl.ListByLabelSelector(context.Background(), a, labels.SelectorFromSet(labels.Set{WorkflowStepLabel: string(v1alpha1.Delete)}))
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(infos).To(Equal([]WorkflowInfo{
+		{Name: "baz-delete-1234abcd-wf", Phase: v1alpha1.Failed, Type: v1alpha1.Delete, CreatedAt: metav1.NewTime(now)},
+	}))
+
+	infos, err = wfl.ListByLabelSelector(context.Background(), a, labels.Everything())
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(infos).To(HaveLen(3))
+}
+
+func TestWorkflowLifecycle_HealthCheck(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	wfl := NewWorkflowLifecycle(fclient, dynfake.NewSimpleDynamicClient(sch), rcdr, sch)
+	g.Expect(wfl.HealthCheck(context.Background())).To(Succeed())
+
+	// The API server returns not found for resources without a CRD
+	gvr := schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha2", Resource: "workflows"}
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	dyn.PrependReactor("list", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.GetResource() != gvr {
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewNotFound(gvr.GroupResource(), "")
+	})
+	wfl = NewWorkflowLifecycleWithOptions(fclient, dyn, rcdr, sch, WithWorkflowGVR(gvr))
+	g.Expect(wfl.HealthCheck(context.Background())).To(MatchError(ContainSubstring("workflows.argoproj.io are not served, make sure the argo workflow CRD is installed")))
+
+	dyn = dynfake.NewSimpleDynamicClient(sch)
+	dyn.PrependReactor("list", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("connection refused")
+	})
+	wfl = NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+	g.Expect(wfl.HealthCheck(context.Background())).To(MatchError("failed to list workflows. connection refused"))
+}
+
+// Test that submitting a workflow that already exists reports the existing workflow
+func TestWorkflowLifecycle_Install_AlreadyExists(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	existing := newTestWorkflow("addon-wf-test", "Running")
+
+	// Another reconcile submits the workflow between the lookup and the create
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	gets := 0
+	dyn.PrependReactor("get", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		gets++
+		if gets == 1 {
+			return true, nil, apierrors.NewNotFound(common.WorkflowGVR().GroupResource(), existing.GetName())
+		}
+		return true, existing.DeepCopy(), nil
+	})
+	dyn.PrependReactor("create", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewAlreadyExists(common.WorkflowGVR().GroupResource(), existing.GetName())
+	})
+
+	recorder := record.NewFakeRecorder(10)
+	wfl := NewWorkflowLifecycle(fclient, dyn, recorder, sch)
+
+	phase, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Running))
+	g.Expect(name).To(Equal("addon-wf-test"))
+	g.Expect(<-recorder.Events).To(HavePrefix("Normal WorkflowExists"))
+}
+
+func TestWorkflowLifecycle_RunDeleteWorkflow(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	newAddon := func() *v1alpha1.Addon {
+		return &v1alpha1.Addon{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "foo",
+				Namespace: "default",
+				UID:       "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5",
+			},
+			Spec: v1alpha1.AddonSpec{
+				PackageSpec: v1alpha1.PackageSpec{
+					PkgName:        "my-addon",
+					PkgVersion:     "1.0.0",
+					PkgType:        v1alpha1.HelmPkg,
+					PkgDescription: "",
+				},
+				Lifecycle: v1alpha1.LifecycleWorkflowSpec{
+					Install: v1alpha1.WorkflowType{Template: wfSpecTemplate},
+					Delete:  v1alpha1.WorkflowType{Template: wfSpecTemplate},
+				},
+			},
+		}
+	}
+
+	// A new delete workflow is submitted under its own name
+	a := newAddon()
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	phase, err := wfl.RunDeleteWorkflow(context.Background(), a, &a.Spec.Lifecycle.Delete)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+
+	deleteName := a.GetFormattedWorkflowName(v1alpha1.Delete)
+	g.Expect(deleteName).To(Not(Equal(a.GetFormattedWorkflowName(v1alpha1.Install))))
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(deleteName, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	// A delete workflow that already ran reports its outcome
+	tests := []struct {
+		wfPhase  string
+		expected v1alpha1.ApplicationAssemblyPhase
+	}{
+		{"Succeeded", v1alpha1.Succeeded},
+		{"Failed", v1alpha1.DeleteFailed},
+	}
+
+	for _, tc := range tests {
+		wf := newTestWorkflow(deleteName, tc.wfPhase)
+		_ = unstructured.SetNestedField(wf.Object, time.Now().Format(time.RFC3339), "status", "startedAt")
+
+		a := newAddon()
+		wfl := NewWorkflowLifecycle(runtimefake.NewFakeClientWithScheme(sch, wf.DeepCopy()), dynfake.NewSimpleDynamicClient(sch, wf.DeepCopy()), rcdr, sch)
+
+		phase, err := wfl.RunDeleteWorkflow(context.Background(), a, &a.Spec.Lifecycle.Delete)
+		g.Expect(err).To(Not(HaveOccurred()), tc.wfPhase)
+		g.Expect(phase).To(Equal(tc.expected), tc.wfPhase)
+	}
+
+	// Nothing to run without a delete workflow
+	phase, err = wfl.RunDeleteWorkflow(context.Background(), a, &v1alpha1.WorkflowType{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Succeeded))
+}
+
+func TestWorkflowLifecycle_DependentsPresent(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	addon := func(name, pkgName string, deps map[string]string) *v1alpha1.Addon {
+		return &v1alpha1.Addon{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5"},
+			Spec: v1alpha1.AddonSpec{
+				PackageSpec: v1alpha1.PackageSpec{PkgName: pkgName, PkgVersion: "v1.0.0", PkgType: v1alpha1.HelmPkg, PkgDeps: deps},
+			},
+		}
+	}
+
+	a := addon("a", "core/A", nil)
+	installed := []runtime.Object{
+		a,
+		addon("c", "core/C", map[string]string{" core/A ": "*"}),
+		addon("b", "core/B", map[string]string{"core/A": "v1.0.0", "core/D": "*"}),
+		addon("d", "core/D", nil),
+	}
+	recorder := record.NewFakeRecorder(10)
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	cl := runtimefake.NewFakeClientWithScheme(sch, installed...)
+	wfl := NewWorkflowLifecycle(cl, dyn, recorder, sch)
+
+	present, dependents, err := wfl.DependentsPresent(context.Background(), a)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(present).To(BeTrue())
+	g.Expect(dependents).To(Equal([]string{"default/b", "default/c"}))
+
+	// The delete workflow waits on the dependents
+	wt := &v1alpha1.WorkflowType{Template: wfSpecTemplate, WaitForDependents: true}
+	phase, err := wfl.RunDeleteWorkflow(context.Background(), a, wt)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	g.Expect(<-recorder.Events).To(Equal("Normal WaitingOnDependents Waiting on dependents default/b, default/c to be deleted"))
+	g.Expect(dyn.Actions()).To(BeEmpty())
+
+	// The event is only recorded again when the dependents change
+	phase, err = wfl.RunDeleteWorkflow(context.Background(), a, wt)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	g.Expect(recorder.Events).To(BeEmpty())
+
+	g.Expect(cl.Delete(context.Background(), installed[1])).To(Succeed())
+	phase, err = wfl.RunDeleteWorkflow(context.Background(), a, wt)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	g.Expect(<-recorder.Events).To(Equal("Normal WaitingOnDependents Waiting on dependents default/b to be deleted"))
+
+	// Without dependents the delete workflow is submitted
+	wfl = NewWorkflowLifecycle(runtimefake.NewFakeClientWithScheme(sch, a, addon("d", "core/D", nil)), dyn, recorder, sch)
+	present, dependents, err = wfl.DependentsPresent(context.Background(), a)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(present).To(BeFalse())
+	g.Expect(dependents).To(BeEmpty())
+
+	phase, err = wfl.RunDeleteWorkflow(context.Background(), a, wt)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(a.GetFormattedWorkflowName(v1alpha1.Delete), metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+}
+
+func TestWorkflowLifecycle_Install_Parallelism(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	var parallelism int64 = 2
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, Parallelism: &parallelism}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	value, found, _ := unstructured.NestedInt64(wf.UnstructuredContent(), "spec", "parallelism")
+	g.Expect(found).To(BeTrue())
+	g.Expect(value).To(Equal(int64(2)))
+
+	// Parallelism is left unset when not configured
+	_, name, err = wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-unbounded")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	_, found, _ = unstructured.NestedFieldNoCopy(wf.UnstructuredContent(), "spec", "parallelism")
+	g.Expect(found).To(BeFalse())
+}
+
+func TestWorkflowLifecycle_GetWorkflow(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	seeded := newTestWorkflow("addon-wf-test", "Running")
+
+	wfl := NewWorkflowLifecycle(fclient, dynfake.NewSimpleDynamicClient(sch, seeded), rcdr, sch)
+
+	wf, err := wfl.GetWorkflow(context.Background(), a, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(wf.GetName()).To(Equal("addon-wf-test"))
+	g.Expect(wf.GetKind()).To(Equal("Workflow"))
+
+	_, err = wfl.GetWorkflow(context.Background(), a, "addon-wf-missing")
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
+
+func TestWorkflowLifecycle_GetOutputs(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	withOutputs := newTestWorkflow("addon-wf-outputs", "Succeeded")
+	_ = unstructured.SetNestedSlice(withOutputs.Object, []interface{}{
+		map[string]interface{}{"name": "endpoint", "value": "https://my-addon.default.svc"},
+		map[string]interface{}{"name": "bucket", "value": "my-addon-bucket"},
+	}, "status", "outputs", "parameters")
+
+	noOutputs := newTestWorkflow("addon-wf-no-outputs", "Succeeded")
+
+	wfl := NewWorkflowLifecycle(fclient, dynfake.NewSimpleDynamicClient(sch, withOutputs, noOutputs), rcdr, sch)
+
+	outputs, err := wfl.GetOutputs(context.Background(), a, "addon-wf-outputs")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(outputs).To(Equal(map[string]string{
+		"endpoint": "https://my-addon.default.svc",
+		"bucket":   "my-addon-bucket",
+	}))
+
+	outputs, err = wfl.GetOutputs(context.Background(), a, "addon-wf-no-outputs")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(outputs).To(BeEmpty())
+	g.Expect(outputs).To(Not(BeNil()))
+}
+
+func TestWorkflowLifecycle_Install_NamePrefix(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	tests := []struct {
+		prefix  string
+		wantErr bool
+	}{
+		{"my-prereq", false},
+		{"v2", false},
+		{"MyPrereq", true},
+		{"-prereq", true},
+		{"prereq-", true},
+		{"my-prereqs-long", true},
+	}
+
+	for _, tc := range tests {
+		wfl := NewWorkflowLifecycle(fclient, dynfake.NewSimpleDynamicClient(sch), rcdr, sch)
+
+		phase, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, NamePrefix: tc.prefix}, "addon-wf-test")
+		if tc.wantErr {
+			g.Expect(err).To(HaveOccurred(), tc.prefix)
+			g.Expect(err.Error()).To(ContainSubstring("invalid namePrefix"), tc.prefix)
+			g.Expect(phase).To(Equal(v1alpha1.Failed), tc.prefix)
+		} else {
+			g.Expect(err).To(Not(HaveOccurred()), tc.prefix)
+			g.Expect(phase).To(Equal(v1alpha1.Pending), tc.prefix)
+		}
+	}
+}
+
+func TestWorkflowLifecycle_Install_NameTemplate(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "core/My_Addon")
+	a.Spec.PkgVersion = "v1.2.0"
+
+	tests := []struct {
+		nameTemplate string
+		expected     string
+		wantErr      bool
+	}{
+		{"{{.PkgName}}-{{.Type}}-{{.PkgVersion}}", "core-my-addon-install-v1-2-0-" + a.CalculateChecksum(), false},
+		{"addon-{{.Type}}-{{.Checksum}}", "addon-install-" + a.CalculateChecksum(), false},
+		{"{{.PkgName", "", true},
+		{"{{.Missing}}", "", true},
+		{"{{.Type}}-" + strings.Repeat("x", 63), "", true},
+	}
+
+	for _, tc := range tests {
+		dyn := dynfake.NewSimpleDynamicClient(sch)
+		wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+		phase, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, NameTemplate: tc.nameTemplate}, a.GetFormattedWorkflowName(v1alpha1.Install))
+		if tc.wantErr {
+			g.Expect(err).To(MatchError(ContainSubstring("invalid nameTemplate")), tc.nameTemplate)
+			g.Expect(phase).To(Equal(v1alpha1.Failed), tc.nameTemplate)
+			g.Expect(dyn.Actions()).To(BeEmpty(), tc.nameTemplate)
+			continue
+		}
+
+		g.Expect(err).To(Not(HaveOccurred()), tc.nameTemplate)
+		g.Expect(name).To(Equal(tc.expected), tc.nameTemplate)
+		_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(tc.expected, metav1.GetOptions{})
+		g.Expect(err).To(Not(HaveOccurred()), tc.nameTemplate)
+	}
+
+	// The step is passed explicitly, the name given to install doesn't matter
+	wfl := NewWorkflowLifecycle(fclient, dynfake.NewSimpleDynamicClient(sch), rcdr, sch)
+	wt := &v1alpha1.WorkflowType{Template: wfSpecTemplate, NameTemplate: "addon-{{.Type}}"}
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Prereqs, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(name).To(Equal("addon-prereqs-" + a.CalculateChecksum()))
+
+	// Changing the addon params submits a workflow with another name
+	changed := a.DeepCopy()
+	changed.Spec.Params.Data = map[string]v1alpha1.FlexString{"replicas": "3"}
+	_, other, err := wfl.Install(context.Background(), changed, v1alpha1.Prereqs, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(other).To(Not(Equal(name)))
+}
+
+// recordingLogger keeps the messages and values logged through it
+type recordingLogger struct {
+	values  []interface{}
+	entries *[]logEntry
+}
+
+type logEntry struct {
+	msg    string
+	values []interface{}
+}
+
+func (l recordingLogger) Info(msg string, keysAndValues ...interface{}) {
+	*l.entries = append(*l.entries, logEntry{msg: msg, values: append(append([]interface{}{}, l.values...), keysAndValues...)})
+}
+
+func (l recordingLogger) Enabled() bool {
+	return true
+}
+
+func (l recordingLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.Info(msg, append(keysAndValues, "error", err)...)
+}
+
+func (l recordingLogger) V(level int) logr.InfoLogger {
+	return l
+}
+
+func (l recordingLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	return recordingLogger{values: append(append([]interface{}{}, l.values...), keysAndValues...), entries: l.entries}
+}
+
+func (l recordingLogger) WithName(name string) logr.Logger {
+	return l
+}
+
+func TestWorkflowLifecycle_Install_Logging(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	var entries []logEntry
+	wfl := NewWorkflowLifecycleWithOptions(fclient, dynfake.NewSimpleDynamicClient(sch), rcdr, sch, WithLogger(recordingLogger{entries: &entries}))
+
+	_, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	g.Expect(entries).To(HaveLen(2))
+	g.Expect(entries[0].msg).To(Equal("submitting workflow"))
+	g.Expect(entries[0].values).To(Equal([]interface{}{"addon", "default/foo", "workflow", "addon-wf-test", "generateName", "", "pkgType", v1alpha1.HelmPkg, "namespace", "default"}))
+	g.Expect(entries[1].msg).To(Equal("submitted workflow"))
+	g.Expect(entries[1].values).To(Equal([]interface{}{"addon", "default/foo", "workflow", "addon-wf-test", "namespace", "default"}))
+
+	// Without a logger nothing is logged and nothing panics
+	wfl = NewWorkflowLifecycleWithOptions(fclient, dynfake.NewSimpleDynamicClient(sch), rcdr, sch, WithLogger(nil))
+	_, _, err = wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+}
+
+func TestWorkflowLifecycle_Install_SuspendOnStart(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	recorder := record.NewFakeRecorder(10)
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, recorder, sch)
+
+	phase, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, SuspendOnStart: true}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	g.Expect(<-recorder.Events).To(HavePrefix("Normal WorkflowSubmitted"))
+	g.Expect(<-recorder.Events).To(Equal("Normal ApprovalRequired Workflow default/addon-wf-test is suspended until it is resumed"))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	suspended, _, _ := unstructured.NestedBool(wf.UnstructuredContent(), "spec", "suspend")
+	g.Expect(suspended).To(BeTrue())
+
+	// Reconciling the submitted workflow doesn't ask for the approval again
+	phase, _, err = wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, SuspendOnStart: true}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	for len(recorder.Events) > 0 {
+		g.Expect(<-recorder.Events).To(Not(ContainSubstring("ApprovalRequired")))
+	}
+
+	// Approving the workflow resumes it
+	g.Expect(wfl.Resume(context.Background(), a, name)).To(Succeed())
+	wf, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	_, found, _ := unstructured.NestedFieldNoCopy(wf.UnstructuredContent(), "spec", "suspend")
+	g.Expect(found).To(BeFalse())
+}
+
+func TestWorkflowLifecycle_Install_SuspendDuration(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	template := `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+spec:
+  entrypoint: install
+  templates:
+    - name: install
+      steps:
+        - - name: approve
+            template: approval
+        - - name: apply
+            template: apply
+    - name: approval
+      suspend: {}
+    - name: apply
+      container:
+        image: alpine:latest
+`
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	// The duration is set on the existing suspend step
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: template, SuspendDuration: "30m"}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	templates, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "templates")
+	g.Expect(templates).To(HaveLen(3))
+	duration, _, _ := unstructured.NestedString(templates[1].(map[string]interface{}), "suspend", "duration")
+	g.Expect(duration).To(Equal("30m"))
+	entrypoint, _, _ := unstructured.NestedString(wf.UnstructuredContent(), "spec", "entrypoint")
+	g.Expect(entrypoint).To(Equal("install"))
+
+	// A workflow without a suspend step is gated before its entrypoint
+	wf, err = wfl.DryRunInstall(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, SuspendDuration: "1h"}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	entrypoint, _, _ = unstructured.NestedString(wf.UnstructuredContent(), "spec", "entrypoint")
+	g.Expect(entrypoint).To(Equal("addon-suspend-entrypoint"))
+	templates, _, _ = unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "templates")
+	g.Expect(templates[len(templates)-2]).To(Equal(map[string]interface{}{
+		"name": "addon-suspend-entrypoint",
+		"steps": []interface{}{
+			[]interface{}{map[string]interface{}{"name": "approve", "template": "addon-suspend-gate"}},
+			[]interface{}{map[string]interface{}{"name": "install", "template": "python-script-example"}},
+		},
+	}))
+	g.Expect(templates[len(templates)-1]).To(Equal(map[string]interface{}{
+		"name":    "addon-suspend-gate",
+		"suspend": map[string]interface{}{"duration": "1h"},
+	}))
+
+	// The entrypoint inputs are passed on from the workflow arguments
+	withInputs := `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+spec:
+  entrypoint: install
+  arguments:
+    parameters:
+      - name: message
+        value: hello
+  templates:
+    - name: install
+      inputs:
+        parameters:
+          - name: message
+          - name: other
+            value: default
+      container:
+        image: alpine:latest
+`
+	wf, err = wfl.DryRunInstall(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: withInputs, SuspendDuration: "1h"}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	templates, _, _ = unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "templates")
+	steps, _, _ := unstructured.NestedSlice(templates[1].(map[string]interface{}), "steps")
+	g.Expect(steps[1]).To(Equal([]interface{}{map[string]interface{}{
+		"name":     "install",
+		"template": "install",
+		"arguments": map[string]interface{}{"parameters": []interface{}{
+			map[string]interface{}{"name": "message", "value": "{{workflow.parameters.message}}"},
+		}},
+	}}))
+
+	phase, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: template, SuspendDuration: "half an hour"}, "addon-wf-invalid")
+	g.Expect(err).To(MatchError(`invalid suspendDuration "half an hour"`))
+	g.Expect(phase).To(Equal(v1alpha1.Failed))
+
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get("addon-wf-invalid", metav1.GetOptions{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
+
+func TestWorkflowLifecycle_Install_PriorityClassName(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, PriorityClassName: "system-cluster-critical"}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	patch, _, _ := unstructured.NestedString(wf.UnstructuredContent(), "spec", "podSpecPatch")
+	g.Expect(patch).To(MatchJSON(`{"priorityClassName": "system-cluster-critical"}`))
+
+	// Nothing is patched without a priority class
+	_, name, err = wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-no-priority")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	_, found, _ := unstructured.NestedFieldNoCopy(wf.UnstructuredContent(), "spec", "podSpecPatch")
+	g.Expect(found).To(BeFalse())
+}
+
+func TestWorkflowLifecycle_Install_PodSpecPatch(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	podSpecPatch := `
+securityContext:
+  runAsNonRoot: true
+  runAsUser: 1000
+`
+	wt := &v1alpha1.WorkflowType{Template: wfSpecTemplate, PodSpecPatch: podSpecPatch, PriorityClassName: "system-cluster-critical"}
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	patch, _, _ := unstructured.NestedString(wf.UnstructuredContent(), "spec", "podSpecPatch")
+	g.Expect(patch).To(MatchJSON(`{"priorityClassName": "system-cluster-critical", "securityContext": {"runAsNonRoot": true, "runAsUser": 1000}}`))
+
+	// JSON patches are accepted as well
+	wf, err = wfl.DryRunInstall(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, PodSpecPatch: `{"containers": [{"name": "main", "securityContext": {"readOnlyRootFilesystem": true}}]}`}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	patch, _, _ = unstructured.NestedString(wf.UnstructuredContent(), "spec", "podSpecPatch")
+	g.Expect(patch).To(MatchJSON(`{"containers": [{"name": "main", "securityContext": {"readOnlyRootFilesystem": true}}]}`))
+
+	phase, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, PodSpecPatch: `{"securityContext": `}, "addon-wf-invalid")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(HavePrefix("invalid podSpecPatch."))
+	g.Expect(phase).To(Equal(v1alpha1.Failed))
+
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get("addon-wf-invalid", metav1.GetOptions{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
+
+func TestWorkflowLifecycle_Install_NodeScheduling(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	wt := &v1alpha1.WorkflowType{
+		Template:     wfSpecTemplate,
+		NodeSelector: map[string]string{"node-role.kubernetes.io/master": ""},
+		Tolerations: []v1.Toleration{
+			{
+				Key:      "node-role.kubernetes.io/master",
+				Operator: v1.TolerationOpExists,
+				Effect:   v1.TaintEffectNoSchedule,
+			},
+		},
+		Affinity: &v1.Affinity{
+			PodAntiAffinity: &v1.PodAntiAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{
+					{
+						LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "my-addon"}},
+						TopologyKey:   "kubernetes.io/hostname",
+					},
+				},
+			},
+		},
+	}
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	nodeSelector, _, _ := unstructured.NestedStringMap(wf.UnstructuredContent(), "spec", "nodeSelector")
+	g.Expect(nodeSelector).To(Equal(map[string]string{"node-role.kubernetes.io/master": ""}))
+
+	tolerations, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "tolerations")
+	g.Expect(tolerations).To(Equal([]interface{}{
+		map[string]interface{}{
+			"key":      "node-role.kubernetes.io/master",
+			"operator": "Exists",
+			"effect":   "NoSchedule",
+		},
+	}))
+
+	affinity, _, _ := unstructured.NestedMap(wf.UnstructuredContent(), "spec", "affinity")
+	g.Expect(affinity).To(Equal(map[string]interface{}{
+		"podAntiAffinity": map[string]interface{}{
+			"requiredDuringSchedulingIgnoredDuringExecution": []interface{}{
+				map[string]interface{}{
+					"labelSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "my-addon"}},
+					"topologyKey":   "kubernetes.io/hostname",
+				},
+			},
+		},
+	}))
+
+	// Nothing is injected when the workflow type has no scheduling constraints
+	_, name, err = wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-unscheduled")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	_, found, _ := unstructured.NestedFieldNoCopy(wf.UnstructuredContent(), "spec", "nodeSelector")
+	g.Expect(found).To(BeFalse())
+	_, found, _ = unstructured.NestedFieldNoCopy(wf.UnstructuredContent(), "spec", "tolerations")
+	g.Expect(found).To(BeFalse())
+	_, found, _ = unstructured.NestedFieldNoCopy(wf.UnstructuredContent(), "spec", "affinity")
+	g.Expect(found).To(BeFalse())
+}
+
+func TestWorkflowLifecycle_ResubmitIfChanged(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	wt := &v1alpha1.WorkflowType{Template: wfSpecTemplate}
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(unstructured.SetNestedField(wf.UnstructuredContent(), "Running", "status", "phase")).To(Succeed())
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Update(wf, metav1.UpdateOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	// The same workflow type is a no-op
+	phase, newName, changed, err := wfl.ResubmitIfChanged(context.Background(), a, v1alpha1.Install, wt, name)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(changed).To(BeFalse())
+	g.Expect(newName).To(Equal(name))
+	g.Expect(phase).To(Equal(v1alpha1.Running))
+
+	// A changed workflow type replaces the workflow
+	parallelism := int64(2)
+	changedWt := &v1alpha1.WorkflowType{Template: wfSpecTemplate, Parallelism: &parallelism}
+	phase, newName, changed, err = wfl.ResubmitIfChanged(context.Background(), a, v1alpha1.Install, changedWt, name)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(changed).To(BeTrue())
+	g.Expect(newName).To(Equal(name))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+
+	wf, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(newName, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	expected, err := wfl.DryRunInstall(context.Background(), a, v1alpha1.Install, changedWt, name)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(wf.GetAnnotations()[WorkflowChecksumAnnotation]).To(Equal(expected.GetAnnotations()[WorkflowChecksumAnnotation]))
+	p, _, _ := unstructured.NestedInt64(wf.UnstructuredContent(), "spec", "parallelism")
+	g.Expect(p).To(Equal(int64(2)))
+}
+
+func TestWorkflowLifecycle_ResubmitIfChanged_WaitsForDeletion(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	wf := newTestWorkflow("addon-wf-test", "")
+	wf.SetUID("old")
+	wf.SetAnnotations(map[string]string{WorkflowChecksumAnnotation: "stale"})
+	_ = unstructured.SetNestedField(wf.Object, "Running", "status", "phase")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch, wf.DeepCopy())
+
+	// The deleted workflow lingers for a few polls
+	var deleted, lingering int
+	dyn.PrependReactor("delete", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		deleted++
+		return false, nil, nil
+	})
+	dyn.PrependReactor("get", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if deleted == 0 || lingering == 2 {
+			return false, nil, nil
+		}
+		lingering++
+		return true, wf.DeepCopy(), nil
+	})
+
+	wt := &v1alpha1.WorkflowType{Template: wfSpecTemplate}
+	wfl := NewWorkflowLifecycleWithOptions(fclient, dyn, rcdr, sch, WithPollInterval(time.Millisecond))
+	phase, name, changed, err := wfl.ResubmitIfChanged(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(changed).To(BeTrue())
+	g.Expect(name).To(Equal("addon-wf-test"))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+	g.Expect(lingering).To(Equal(2))
+	g.Expect(deleted).To(Equal(1))
+
+	created, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(created.GetAnnotations()[WorkflowChecksumAnnotation]).To(Not(Equal("stale")))
+
+	// The replacement is not created if the old workflow is never gone
+	dyn = dynfake.NewSimpleDynamicClient(sch, wf.DeepCopy())
+	dyn.PrependReactor("delete", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	wfl = NewWorkflowLifecycleWithOptions(fclient, dyn, rcdr, sch, WithPollInterval(time.Millisecond))
+	phase, _, _, err = wfl.ResubmitIfChanged(ctx, a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(phase).To(Equal(v1alpha1.Failed))
+	for _, action := range dyn.Actions() {
+		g.Expect(action.GetVerb()).To(Not(Equal("create")))
+	}
+}
+
+func TestWorkflowLifecycle_WorkflowConditions(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	tests := []struct {
+		name     string
+		status   map[string]interface{}
+		expected []v1alpha1.Condition
+	}{
+		{
+			name:     "no-status",
+			expected: []v1alpha1.Condition{},
+		},
+		{
+			name: "succeeded",
+			status: map[string]interface{}{
+				"phase": "Succeeded",
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Completed", "status": "True"},
+				},
+			},
+			expected: []v1alpha1.Condition{
+				{Type: v1alpha1.ConditionInstalled, Status: v1.ConditionTrue, Reason: "WorkflowSucceeded"},
+				{Type: v1alpha1.ConditionProgressing, Status: v1.ConditionFalse, Reason: "WorkflowSucceeded"},
+				{Type: v1alpha1.ConditionDegraded, Status: v1.ConditionFalse, Reason: "WorkflowSucceeded"},
+			},
+		},
+		{
+			name: "failed",
+			status: map[string]interface{}{
+				"phase":   "Failed",
+				"message": "child 'install' failed",
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Completed", "status": "True"},
+				},
+			},
+			expected: []v1alpha1.Condition{
+				{Type: v1alpha1.ConditionInstalled, Status: v1.ConditionFalse, Reason: "WorkflowFailed", Message: "child 'install' failed"},
+				{Type: v1alpha1.ConditionProgressing, Status: v1.ConditionFalse, Reason: "WorkflowFailed", Message: "child 'install' failed"},
+				{Type: v1alpha1.ConditionDegraded, Status: v1.ConditionTrue, Reason: "WorkflowFailed", Message: "child 'install' failed"},
+			},
+		},
+		{
+			name: "running-with-warning",
+			status: map[string]interface{}{
+				"phase": "Running",
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "PodRunning", "status": "True"},
+					map[string]interface{}{"type": "SpecWarning", "status": "True", "message": "template is deprecated"},
+				},
+			},
+			expected: []v1alpha1.Condition{
+				{Type: v1alpha1.ConditionInstalled, Status: v1.ConditionFalse, Reason: "WorkflowRunning"},
+				{Type: v1alpha1.ConditionProgressing, Status: v1.ConditionTrue, Reason: "WorkflowRunning"},
+				{Type: v1alpha1.ConditionDegraded, Status: v1.ConditionTrue, Reason: "SpecWarning", Message: "template is deprecated"},
+			},
+		},
+	}
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	for _, tt := range tests {
+		wf := newTestWorkflow(tt.name, "")
+		if tt.status != nil {
+			wf.Object["status"] = tt.status
+		}
+		_, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Create(wf, metav1.CreateOptions{})
+		g.Expect(err).To(Not(HaveOccurred()))
+
+		conditions, err := wfl.WorkflowConditions(context.Background(), a, tt.name)
+		g.Expect(err).To(Not(HaveOccurred()), tt.name)
+		g.Expect(conditions).To(Equal(tt.expected), tt.name)
+	}
+
+	_, err := wfl.WorkflowConditions(context.Background(), a, "missing")
+	g.Expect(errors.Is(err, ErrWorkflowNotFound)).To(BeTrue())
+}
+
+func TestWorkflowLifecycle_Install_RegistryMirror(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	images := func(wf *unstructured.Unstructured) map[string]string {
+		result := make(map[string]string)
+		templates, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "templates")
+		for _, template := range templates {
+			template := template.(map[string]interface{})
+			name := template["name"].(string)
+			if image, found, _ := unstructured.NestedString(template, "container", "image"); found {
+				result[name] = image
+			}
+			if image, found, _ := unstructured.NestedString(template, "script", "image"); found {
+				result[name] = image
+			}
+		}
+		return result
+	}
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycleWithOptions(fclient, dyn, rcdr, sch, WithRegistryMirror("mirror.example.com/"))
+
+	wf, err := wfl.DryRunInstall(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(images(wf)).To(Equal(map[string]string{
+		"gen-random-int": "mirror.example.com/python:alpine3.6",
+		"print-message":  "mirror.example.com/alpine:latest",
+	}))
+
+	// The mirror host is prepended keeping the image path, images already on the mirror are left as is
+	for image, expected := range map[string]string{
+		"quay.io/argoproj/argoexec:v2.4.0":  "mirror.example.com/quay.io/argoproj/argoexec:v2.4.0",
+		"localhost:5000/tools/kubectl:1.15": "mirror.example.com/localhost:5000/tools/kubectl:1.15",
+		"keikoproj/addon-manager:latest":    "mirror.example.com/keikoproj/addon-manager:latest",
+		"mirror.example.com/alpine:3.10":    "mirror.example.com/alpine:3.10",
+	} {
+		g.Expect(mirrorImage("mirror.example.com", image)).To(Equal(expected))
+	}
+
+	// The same image path on different registries doesn't collide on the mirror
+	g.Expect(mirrorImage("mirror.example.com", "quay.io/foo/bar:1.0")).To(Equal("mirror.example.com/quay.io/foo/bar:1.0"))
+	g.Expect(mirrorImage("mirror.example.com", "gcr.io/foo/bar:1.0")).To(Equal("mirror.example.com/gcr.io/foo/bar:1.0"))
+	g.Expect(mirrorImage("mirror.example.com", "foo/bar:1.0")).To(Equal("mirror.example.com/foo/bar:1.0"))
+
+	// No mirror leaves the images unchanged
+	wfl = NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+	wf, err = wfl.DryRunInstall(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(images(wf)).To(Equal(map[string]string{
+		"gen-random-int": "python:alpine3.6",
+		"print-message":  "alpine:latest",
+	}))
+}
+
+func TestUpdateTemplates(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	wf := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"templates": []interface{}{
+				map[string]interface{}{"name": "a", "container": map[string]interface{}{"image": "alpine"}},
+				"not-a-template",
+			},
+		},
+	}}
+
+	// Updates are written back to the workflow
+	err := updateTemplates(wf, func(template map[string]interface{}) error {
+		template["retryStrategy"] = map[string]interface{}{"limit": "2"}
+		return nil
+	})
+	g.Expect(err).To(Not(HaveOccurred()))
+	limit, _, _ := unstructured.NestedString(wf.Object["spec"].(map[string]interface{})["templates"].([]interface{})[0].(map[string]interface{}), "retryStrategy", "limit")
+	g.Expect(limit).To(Equal("2"))
+
+	// Templates that are not a list fail instead of panicking
+	wf.Object["spec"] = map[string]interface{}{"templates": map[string]interface{}{"name": "a"}}
+	err = updateTemplates(wf, func(template map[string]interface{}) error { return nil })
+	g.Expect(errors.Is(err, ErrInvalidTemplate)).To(BeTrue())
+
+	// Workflows without templates are left as is
+	wf.Object["spec"] = map[string]interface{}{"workflowTemplateRef": map[string]interface{}{"name": "a"}}
+	g.Expect(updateTemplates(wf, func(template map[string]interface{}) error { return errors.New("not called") })).To(Succeed())
+}
+
+func TestWorkflowLifecycle_DeleteByAddon(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	for name, addon := range map[string]string{
+		"foo-prereqs-wf": "foo",
+		"foo-install-wf": "foo",
+		"foo-delete-wf":  "foo",
+		"bar-install-wf": "bar",
+	} {
+		wf := newTestWorkflow(name, "")
+		wf.SetLabels(map[string]string{WorkflowAddonLabel: addon})
+		wf.SetAnnotations(map[string]string{WorkflowSourceAnnotation: "default/" + addon})
+		_, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Create(wf, metav1.CreateOptions{})
+		g.Expect(err).To(Not(HaveOccurred()))
+	}
+
+	// A workflow removed by someone else while deleting is not an error
+	dyn.PrependReactor("delete", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.(clienttesting.DeleteAction).GetName() == "foo-delete-wf" {
+			return true, nil, apierrors.NewNotFound(common.WorkflowGVR().GroupResource(), "foo-delete-wf")
+		}
+		return false, nil, nil
+	})
+
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+	g.Expect(wfl.DeleteByAddon(context.Background(), a)).To(Succeed())
+
+	workflows, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").List(metav1.ListOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	var names []string
+	for _, wf := range workflows.Items {
+		names = append(names, wf.GetName())
+	}
+	g.Expect(names).To(ConsistOf("foo-delete-wf", "bar-install-wf"))
+
+	// Delete failures are aggregated
+	dyn.PrependReactor("delete", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("apiserver unavailable")
+	})
+	err = wfl.DeleteByAddon(context.Background(), a)
+	g.Expect(err).To(MatchError("failed to delete workflow default/foo-delete-wf. apiserver unavailable"))
+}
+
+func TestWorkflowLifecycle_Install_WorkflowGVR(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	gvr := schema.GroupVersionResource{Group: "argoproj.io", Version: "v1beta1", Resource: "workflows"}
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycleWithOptions(fclient, dyn, rcdr, sch, WithWorkflowGVR(gvr))
+
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(gvr).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(wf.GetAPIVersion()).To(Equal("argoproj.io/v1beta1"))
+
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+	phase, err := wfl.GetStatus(context.Background(), a, name)
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+}
+
+func TestWorkflowLifecycle_GetFailedNodeMessages(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	wf := newTestWorkflow("addon-wf-test", "")
+	wf.Object["status"] = map[string]interface{}{
+		"phase": "Failed",
+		"nodes": map[string]interface{}{
+			"addon-wf-test": map[string]interface{}{
+				"name":    "addon-wf-test",
+				"phase":   "Failed",
+				"message": "child 'addon-wf-test-2' failed",
+			},
+			"addon-wf-test-1": map[string]interface{}{
+				"name":  "addon-wf-test[0].generate",
+				"phase": "Succeeded",
+			},
+			"addon-wf-test-2": map[string]interface{}{
+				"name":    "addon-wf-test[1].print",
+				"phase":   "Error",
+				"message": "failed to pull image",
+			},
+			"addon-wf-test-3": map[string]interface{}{
+				"name":  "addon-wf-test[1].cleanup",
+				"phase": "Running",
+			},
+		},
+	}
+
+	dyn := dynfake.NewSimpleDynamicClient(sch, wf)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	messages, err := wfl.GetFailedNodeMessages(context.Background(), a, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(messages).To(Equal(map[string]string{
+		"addon-wf-test":          "child 'addon-wf-test-2' failed",
+		"addon-wf-test[1].print": "failed to pull image",
+	}))
+
+	_, err = wfl.GetFailedNodeMessages(context.Background(), a, "missing")
+	g.Expect(errors.Is(err, ErrWorkflowNotFound)).To(BeTrue())
+}
+
+func TestWorkflowLifecycle_Progress(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+			UID:       "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5",
+		},
+	}
+
+	workflow := func(name string, nodes map[string]interface{}) *unstructured.Unstructured {
+		wf := newTestWorkflow(name, "")
+		if nodes != nil {
+			wf.Object["status"] = map[string]interface{}{"phase": "Running", "nodes": nodes}
+		}
+		return wf
+	}
+	node := func(nodeType, phase string) map[string]interface{} {
+		return map[string]interface{}{"type": nodeType, "phase": phase}
+	}
+
+	dyn := dynfake.NewSimpleDynamicClient(sch,
+		workflow("addon-wf-running", map[string]interface{}{
+			"addon-wf-running":   node("Steps", "Running"),
+			"addon-wf-running-1": node("StepGroup", "Succeeded"),
+			"addon-wf-running-2": node("Pod", "Succeeded"),
+			"addon-wf-running-3": node("Pod", "Failed"),
+			"addon-wf-running-4": node("Pod", "Running"),
+			"addon-wf-running-5": node("Pod", "Pending"),
+		}),
+		workflow("addon-wf-new", nil),
+	)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	completed, total, err := wfl.Progress(context.Background(), a, "addon-wf-running")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(completed).To(Equal(2))
+	g.Expect(total).To(Equal(4))
+
+	completed, total, err = wfl.Progress(context.Background(), a, "addon-wf-new")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(completed).To(Equal(0))
+	g.Expect(total).To(Equal(0))
+
+	_, _, err = wfl.Progress(context.Background(), a, "addon-wf-missing")
+	g.Expect(errors.Is(err, ErrWorkflowNotFound)).To(BeTrue())
+}
+
+// Test that lifecycles can be used from concurrent reconciles, run with -race
+func TestWorkflowLifecycle_Install_Concurrent(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	newAddon := func(name string) *v1alpha1.Addon {
+		return &v1alpha1.Addon{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				UID:       types.UID("2a3cd8d3-ba3d-4a49-9a2b-" + name),
+			},
+			Spec: v1alpha1.AddonSpec{
+				PackageSpec: v1alpha1.PackageSpec{
+					PkgName:        name,
+					PkgVersion:     "1.0.0",
+					PkgType:        v1alpha1.HelmPkg,
+					PkgDescription: "",
+				},
+			},
+		}
+	}
+
+	foo, bar := newAddon("foo"), newAddon("bar")
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i, a := range []*v1alpha1.Addon{foo, bar, foo, bar} {
+		wg.Add(1)
+		go func(i int, a *v1alpha1.Addon) {
+			defer wg.Done()
+			_, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, fmt.Sprintf("addon-wf-test-%d", i))
+			errs <- err
+		}(i, a)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		g.Expect(err).To(Not(HaveOccurred()))
+	}
+
+	for i, addon := range []string{"foo", "bar", "foo", "bar"} {
+		wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(fmt.Sprintf("addon-wf-test-%d", i), metav1.GetOptions{})
+		g.Expect(err).To(Not(HaveOccurred()))
+		g.Expect(wf.GetLabels()[WorkflowAddonLabel]).To(Equal(addon))
+		g.Expect(wf.GetOwnerReferences()[0].Name).To(Equal(addon))
+	}
+
+	// The last workflow is tracked by addon
+	fooLast, _ := wfl.LastWorkflow(foo)
+	barLast, _ := wfl.LastWorkflow(bar)
+	g.Expect([]string{"addon-wf-test-0", "addon-wf-test-2"}).To(ContainElement(fooLast))
+	g.Expect([]string{"addon-wf-test-1", "addon-wf-test-3"}).To(ContainElement(barLast))
+}
+
+func TestWorkflowLifecycle_Install_ArchiveLogs(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	archiveLogs := true
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, ArchiveLogs: &archiveLogs}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	archived, found, _ := unstructured.NestedBool(wf.UnstructuredContent(), "spec", "archiveLogs")
+	g.Expect(found).To(BeTrue())
+	g.Expect(archived).To(BeTrue())
+
+	// Unset leaves the argo controller default
+	wf, err = wfl.DryRunInstall(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-default")
+	g.Expect(err).To(Not(HaveOccurred()))
+	_, found, _ = unstructured.NestedFieldNoCopy(wf.UnstructuredContent(), "spec", "archiveLogs")
+	g.Expect(found).To(BeFalse())
+}
+
+func TestWorkflowLifecycle_Install_PodResources(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	template := `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+spec:
+  entrypoint: entry
+  templates:
+    - name: entry
+      steps:
+        - - name: install
+            template: install
+    - name: install
+      container:
+        image: alpine:latest
+    - name: verify
+      script:
+        image: python:alpine3.6
+        resources:
+          limits:
+            memory: 1Gi
+`
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	wt := &v1alpha1.WorkflowType{
+		Template: template,
+		PodResources: &v1.ResourceRequirements{
+			Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m")},
+			Limits:   v1.ResourceList{v1.ResourceMemory: resource.MustParse("256Mi")},
+		},
+	}
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	templates, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "templates")
+
+	// The steps template runs no pod
+	_, found, _ := unstructured.NestedFieldNoCopy(templates[0].(map[string]interface{}), "resources")
+	g.Expect(found).To(BeFalse())
+
+	resources, _, _ := unstructured.NestedMap(templates[1].(map[string]interface{}), "container", "resources")
+	g.Expect(resources).To(Equal(map[string]interface{}{
+		"requests": map[string]interface{}{"cpu": "100m"},
+		"limits":   map[string]interface{}{"memory": "256Mi"},
+	}))
+
+	// Resources given in the template take precedence
+	resources, _, _ = unstructured.NestedMap(templates[2].(map[string]interface{}), "script", "resources")
+	g.Expect(resources).To(Equal(map[string]interface{}{
+		"limits": map[string]interface{}{"memory": "1Gi"},
+	}))
+}
+
+func TestWorkflowLifecycle_FindExistingWorkflow(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	now := time.Now()
+	var objects []runtime.Object
+	for _, wf := range []struct {
+		name    string
+		addon   string
+		step    v1alpha1.LifecycleStep
+		phase   string
+		created time.Time
+	}{
+		{"foo-install-1111-wf", "foo", v1alpha1.Install, "Succeeded", now.Add(-2 * time.Hour)},
+		{"foo-install-2222-wf", "foo", v1alpha1.Install, "Running", now.Add(-time.Hour)},
+		{"foo-install-3333-wf", "foo", v1alpha1.Install, "Pending", now},
+		{"foo-prereqs-3333-wf", "foo", v1alpha1.Prereqs, "Succeeded", now},
+		{"bar-delete-4444-wf", "bar", v1alpha1.Delete, "Running", now},
+		{"foo-custom-name-wf", "foo", v1alpha1.Delete, "Running", now.Add(-time.Hour)},
+	} {
+		obj := newTestWorkflow(wf.name, "")
+		obj.SetCreationTimestamp(metav1.NewTime(wf.created))
+		obj.SetLabels(map[string]string{WorkflowAddonLabel: wf.addon, WorkflowStepLabel: string(wf.step)})
+		obj.SetAnnotations(map[string]string{WorkflowSourceAnnotation: "default/" + wf.addon})
+		_ = unstructured.SetNestedField(obj.Object, wf.phase, "status", "phase")
+		objects = append(objects, obj)
+	}
+
+	wfl := NewWorkflowLifecycle(fclient, dynfake.NewSimpleDynamicClient(sch, objects...), rcdr, sch)
+
+	// The most recent workflow that is still running is adopted
+	name, phase, found, err := wfl.FindExistingWorkflow(context.Background(), "default", "foo", string(v1alpha1.Install))
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(found).To(BeTrue())
+	g.Expect(name).To(Equal("foo-install-3333-wf"))
+	g.Expect(phase).To(Equal(v1alpha1.Pending))
+
+	// Completed workflows are not adopted
+	_, _, found, err = wfl.FindExistingWorkflow(context.Background(), "default", "foo", string(v1alpha1.Prereqs))
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(found).To(BeFalse())
+
+	// Workflows are matched by their step label, not by their name
+	name, _, found, err = wfl.FindExistingWorkflow(context.Background(), "default", "foo", string(v1alpha1.Delete))
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(found).To(BeTrue())
+	g.Expect(name).To(Equal("foo-custom-name-wf"))
+
+	name, phase, found, err = wfl.FindExistingWorkflow(context.Background(), "default", "bar", string(v1alpha1.Delete))
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(found).To(BeTrue())
+	g.Expect(name).To(Equal("bar-delete-4444-wf"))
+	g.Expect(phase).To(Equal(v1alpha1.Running))
+}
+
+func TestWorkflowLifecycle_Install_Env(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	template := `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+spec:
+  entrypoint: install
+  templates:
+    - name: install
+      container:
+        image: alpine:latest
+        env:
+          - name: HTTPS_PROXY
+            value: http://template-proxy:3128
+    - name: verify
+      script:
+        image: python:alpine3.6
+`
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	wt := &v1alpha1.WorkflowType{
+		Template: template,
+		Env: []v1.EnvVar{
+			{Name: "HTTPS_PROXY", Value: "http://proxy:3128"},
+			{Name: "FEATURE_FLAG", Value: "enabled"},
+		},
+	}
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	templates, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "templates")
+
+	env, _, _ := unstructured.NestedSlice(templates[0].(map[string]interface{}), "container", "env")
+	g.Expect(env).To(Equal([]interface{}{
+		map[string]interface{}{"name": "HTTPS_PROXY", "value": "http://template-proxy:3128"},
+		map[string]interface{}{"name": "FEATURE_FLAG", "value": "enabled"},
+	}))
+
+	env, _, _ = unstructured.NestedSlice(templates[1].(map[string]interface{}), "script", "env")
+	g.Expect(env).To(Equal([]interface{}{
+		map[string]interface{}{"name": "HTTPS_PROXY", "value": "http://proxy:3128"},
+		map[string]interface{}{"name": "FEATURE_FLAG", "value": "enabled"},
+	}))
+
+	// An empty env leaves the templates unchanged
+	wf, err = wfl.DryRunInstall(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: template}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	templates, _, _ = unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "templates")
+	env, _, _ = unstructured.NestedSlice(templates[0].(map[string]interface{}), "container", "env")
+	g.Expect(env).To(HaveLen(1))
+	_, found, _ := unstructured.NestedFieldNoCopy(templates[1].(map[string]interface{}), "script", "env")
+	g.Expect(found).To(BeFalse())
+}
+
+func TestWorkflowLifecycle_Install_InitContainers(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	wt := &v1alpha1.WorkflowType{
+		Template: wfSpecTemplate,
+		InitContainers: []v1.Container{
+			{Name: "wait-for-db", Image: "busybox", Command: []string{"sh", "-c", "until nc -z db 5432; do sleep 1; done"}},
+		},
+	}
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	templates, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "templates")
+	for _, template := range templates {
+		template := template.(map[string]interface{})
+		initContainers, found, _ := unstructured.NestedSlice(template, "initContainers")
+		if template["script"] == nil && template["container"] == nil {
+			g.Expect(found).To(BeFalse(), template["name"])
+			continue
+		}
+		g.Expect(initContainers).To(HaveLen(1), template["name"])
+		g.Expect(initContainers[0]).To(HaveKeyWithValue("name", "wait-for-db"))
+		g.Expect(initContainers[0]).To(HaveKeyWithValue("image", "busybox"))
+	}
+}
+
+func TestWorkflowLifecycle_Install_MountSecrets(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+	a.Spec.Secrets = []v1alpha1.SecretCmdSpec{
+		{Name: "db.credentials"},
+		{Name: "tls", Items: []v1.KeyToPath{{Key: "tls.crt", Path: "cert.pem"}}},
+	}
+
+	template := `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+spec:
+  entrypoint: install
+  volumes:
+    - name: secret-tls
+      secret:
+        secretName: tls
+  templates:
+    - name: install
+      container:
+        image: alpine:latest
+        volumeMounts:
+          - name: secret-tls
+            mountPath: /tls
+    - name: verify
+      script:
+        image: python:alpine3.6
+`
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	wt := &v1alpha1.WorkflowType{Template: template, MountSecrets: true}
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	// The volume already in the template is not duplicated
+	volumes, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "volumes")
+	g.Expect(volumes).To(Equal([]interface{}{
+		map[string]interface{}{"name": "secret-tls", "secret": map[string]interface{}{"secretName": "tls"}},
+		map[string]interface{}{"name": "secret-db-credentials", "secret": map[string]interface{}{"secretName": "db.credentials"}},
+	}))
+
+	templates, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "templates")
+	mounts, _, _ := unstructured.NestedSlice(templates[0].(map[string]interface{}), "container", "volumeMounts")
+	g.Expect(mounts).To(Equal([]interface{}{
+		map[string]interface{}{"name": "secret-tls", "mountPath": "/tls"},
+		map[string]interface{}{"name": "secret-db-credentials", "mountPath": "/etc/addon/secrets/db.credentials", "readOnly": true},
+	}))
+
+	mounts, _, _ = unstructured.NestedSlice(templates[1].(map[string]interface{}), "script", "volumeMounts")
+	g.Expect(mounts).To(Equal([]interface{}{
+		map[string]interface{}{"name": "secret-db-credentials", "mountPath": "/etc/addon/secrets/db.credentials", "readOnly": true},
+		map[string]interface{}{"name": "secret-tls", "mountPath": "/etc/addon/secrets/tls", "readOnly": true},
+	}))
+
+	// Items select the projected keys of the secret
+	wf, err = wfl.DryRunInstall(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, MountSecrets: true}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	volumes, _, _ = unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "volumes")
+	g.Expect(volumes).To(HaveLen(2))
+	g.Expect(volumes[1]).To(Equal(map[string]interface{}{
+		"name": "secret-tls",
+		"secret": map[string]interface{}{
+			"secretName": "tls",
+			"items":      []interface{}{map[string]interface{}{"key": "tls.crt", "path": "cert.pem"}},
+		},
+	}))
+
+	// A template volume with the same name for another secret is rejected
+	conflicting := strings.Replace(template, "secretName: tls", "secretName: other", 1)
+	_, err = wfl.DryRunInstall(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: conflicting, MountSecrets: true}, "addon-wf-test")
+	g.Expect(err).To(HaveOccurred())
+
+	// Secrets are only mounted when requested
+	wf, err = wfl.DryRunInstall(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	_, found, _ := unstructured.NestedFieldNoCopy(wf.UnstructuredContent(), "spec", "volumes")
+	g.Expect(found).To(BeFalse())
+}
+
+func TestWorkflowLifecycle_Install_Volumes(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	template := `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+spec:
+  entrypoint: install
+  volumes:
+    - name: workdir
+      emptyDir: {}
+  templates:
+    - name: install
+      container:
+        image: alpine:latest
+        volumeMounts:
+          - name: workdir
+            mountPath: /cache
+    - name: verify
+      script:
+        image: python:alpine3.6
+`
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	wt := &v1alpha1.WorkflowType{
+		Template: template,
+		Volumes: []v1.Volume{
+			{Name: "helm-cache", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+			{Name: "workdir", VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: "/tmp"}}},
+		},
+		VolumeMounts: []v1.VolumeMount{
+			{Name: "helm-cache", MountPath: "/cache"},
+		},
+	}
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	// The template volume with the same name is kept
+	volumes, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "volumes")
+	g.Expect(volumes).To(Equal([]interface{}{
+		map[string]interface{}{"name": "workdir", "emptyDir": map[string]interface{}{}},
+		map[string]interface{}{"name": "helm-cache", "emptyDir": map[string]interface{}{}},
+	}))
+
+	// The template mount at the same path is kept
+	templates, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "templates")
+	mounts, _, _ := unstructured.NestedSlice(templates[0].(map[string]interface{}), "container", "volumeMounts")
+	g.Expect(mounts).To(Equal([]interface{}{
+		map[string]interface{}{"name": "workdir", "mountPath": "/cache"},
+	}))
+
+	mounts, _, _ = unstructured.NestedSlice(templates[1].(map[string]interface{}), "script", "volumeMounts")
+	g.Expect(mounts).To(Equal([]interface{}{
+		map[string]interface{}{"name": "helm-cache", "mountPath": "/cache"},
+	}))
+
+	// Nothing is injected without volumes
+	wf, err = wfl.DryRunInstall(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	_, found, _ := unstructured.NestedFieldNoCopy(wf.UnstructuredContent(), "spec", "volumes")
+	g.Expect(found).To(BeFalse())
+}
+
+func TestWorkflowLifecycle_Terminate(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+			UID:       "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5",
+		},
+	}
+
+	workflow := func(name, phase string) *unstructured.Unstructured {
+		wf := newTestWorkflow(name, phase)
+		return wf
+	}
+
+	dyn := dynfake.NewSimpleDynamicClient(sch, workflow("addon-wf-running", "Running"), workflow("addon-wf-succeeded", "Succeeded"))
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	g.Expect(wfl.Terminate(context.Background(), a, "addon-wf-running")).To(Succeed())
+
+	var patches []string
+	for _, action := range dyn.Actions() {
+		if patch, ok := action.(clienttesting.PatchAction); ok {
+			patches = append(patches, string(patch.GetPatch()))
+		}
+	}
+	g.Expect(patches).To(HaveLen(1))
+	g.Expect(patches[0]).To(MatchJSON(`{"spec": {"shutdown": "Terminate"}}`))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get("addon-wf-running", metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	shutdown, _, _ := unstructured.NestedString(wf.Object, "spec", "shutdown")
+	g.Expect(shutdown).To(Equal("Terminate"))
+
+	// Completed workflows are not patched
+	dyn.ClearActions()
+	err = wfl.Terminate(context.Background(), a, "addon-wf-succeeded")
+	g.Expect(errors.Is(err, ErrWorkflowCompleted)).To(BeTrue())
+	err = wfl.Terminate(context.Background(), a, "addon-wf-missing")
+	g.Expect(errors.Is(err, ErrWorkflowNotFound)).To(BeTrue())
+	for _, action := range dyn.Actions() {
+		g.Expect(action.GetVerb()).To(Equal("get"))
+	}
+}
+
+func TestWorkflowLifecycle_AddWorkflowLabels(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+			UID:       "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5",
+		},
+	}
+
+	wf := newTestWorkflow("addon-wf", "")
+	wf.SetLabels(map[string]string{"app": "foo"})
+
+	dyn := dynfake.NewSimpleDynamicClient(sch, wf)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	g.Expect(wfl.AddWorkflowLabels(context.Background(), a, "addon-wf", map[string]string{"tier": "infra", "app.kubernetes.io/version": "1.0.0"})).To(Succeed())
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get("addon-wf", metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(wf.GetLabels()).To(Equal(map[string]string{"app": "foo", "tier": "infra", "app.kubernetes.io/version": "1.0.0"}))
+
+	// Invalid labels are not patched
+	dyn.ClearActions()
+	g.Expect(wfl.AddWorkflowLabels(context.Background(), a, "addon-wf", map[string]string{"tier": "not a valid value"})).To(Not(Succeed()))
+	g.Expect(dyn.Actions()).To(BeEmpty())
+
+	err = wfl.AddWorkflowLabels(context.Background(), a, "addon-wf-missing", map[string]string{"tier": "infra"})
+	g.Expect(errors.Is(err, ErrWorkflowNotFound)).To(BeTrue())
+}
+
+func TestWorkflowLifecycle_Install_ArtifactRepositoryRef(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	wt := &v1alpha1.WorkflowType{
+		Template:              wfSpecTemplate,
+		ArtifactRepositoryRef: &v1alpha1.ArtifactRepositoryRef{ConfigMap: "artifact-repositories", Key: "addons"},
+	}
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	ref, found, _ := unstructured.NestedStringMap(wf.UnstructuredContent(), "spec", "artifactRepositoryRef")
+	g.Expect(found).To(BeTrue())
+	g.Expect(ref).To(Equal(map[string]string{"configMap": "artifact-repositories", "key": "addons"}))
+
+	// Unset leaves the argo default
+	wf, err = wfl.DryRunInstall(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	_, found, _ = unstructured.NestedFieldNoCopy(wf.UnstructuredContent(), "spec", "artifactRepositoryRef")
+	g.Expect(found).To(BeFalse())
+}
+
+func TestWorkflowLifecycle_Install_Metrics(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	wt := &v1alpha1.WorkflowType{
+		Template: wfSpecTemplate,
+		Metrics: []v1alpha1.WorkflowMetric{
+			{Name: "addon_install_duration", Help: "Duration of the addon install", Type: "gauge", Labels: map[string]string{"pkg": "my-addon", "addon": "foo"}},
+			{Name: "addon_install_total", Help: "Count of addon installs", Type: "counter"},
+		},
+	}
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	metrics, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "metrics", "prometheus")
+	g.Expect(metrics).To(Equal([]interface{}{
+		map[string]interface{}{
+			"name":  "addon_install_duration",
+			"help":  "Duration of the addon install",
+			"gauge": map[string]interface{}{"value": "{{workflow.duration}}"},
+			"labels": []interface{}{
+				map[string]interface{}{"key": "addon", "value": "foo"},
+				map[string]interface{}{"key": "pkg", "value": "my-addon"},
+			},
+		},
+		map[string]interface{}{
+			"name":    "addon_install_total",
+			"help":    "Count of addon installs",
+			"counter": map[string]interface{}{"value": "1"},
+		},
+	}))
+
+	// No metrics leaves the workflow unchanged
+	wf, err = wfl.DryRunInstall(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	_, found, _ := unstructured.NestedFieldNoCopy(wf.UnstructuredContent(), "spec", "metrics")
+	g.Expect(found).To(BeFalse())
+
+	// Metrics of unknown types are rejected
+	_, err = wfl.DryRunInstall(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{
+		Template: wfSpecTemplate,
+		Metrics:  []v1alpha1.WorkflowMetric{{Name: "addon_install", Help: "Addon install", Type: "histogram"}},
+	}, "addon-wf-test")
+	g.Expect(err).To(MatchError(`invalid metric "addon_install", unknown type "histogram"`))
+}
+
+func TestWorkflowLifecycle_EnsureInstalled(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	workflow := func(name, phase string) *unstructured.Unstructured {
+		wf := newTestWorkflow(name, phase)
+		return wf
+	}
+
+	dyn := dynfake.NewSimpleDynamicClient(sch,
+		workflow("addon-wf-running", "Running"),
+		workflow("addon-wf-failed", "Failed"),
+		workflow("addon-wf-succeeded", "Succeeded"),
+	)
+	// The fake client doesn't generate names, so mimic the API server
+	dyn.PrependReactor("create", "workflows", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		obj := action.(clienttesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		if obj.GetName() == "" {
+			obj.SetName(obj.GetGenerateName() + "abcde")
+		}
+		return false, nil, nil
+	})
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+	wt := &v1alpha1.WorkflowType{Template: wfSpecTemplate}
+
+	tests := []struct {
+		existing      string
+		expectedPhase v1alpha1.ApplicationAssemblyPhase
+		expectedName  string
+	}{
+		{"", v1alpha1.Pending, "my-addon-abcde"},
+		{"addon-wf-gone", v1alpha1.Pending, "addon-wf-gone"},
+		{"addon-wf-running", v1alpha1.Running, "addon-wf-running"},
+		{"addon-wf-failed", v1alpha1.Failed, "addon-wf-failed"},
+		{"addon-wf-succeeded", v1alpha1.Succeeded, "addon-wf-succeeded"},
+	}
+
+	for _, tt := range tests {
+		dyn.ClearActions()
+		phase, name, err := wfl.EnsureInstalled(context.Background(), a, v1alpha1.Install, wt, tt.existing)
+		g.Expect(err).To(Not(HaveOccurred()), tt.existing)
+		g.Expect(phase).To(Equal(tt.expectedPhase), tt.existing)
+		g.Expect(name).To(Equal(tt.expectedName), tt.existing)
+
+		_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+		g.Expect(err).To(Not(HaveOccurred()), tt.existing)
+
+		// Existing workflows are never deleted or resubmitted
+		if tt.expectedPhase != v1alpha1.Pending {
+			for _, action := range dyn.Actions() {
+				g.Expect(action.GetVerb()).To(Equal("get"), tt.existing)
+			}
+		}
+	}
+}
+
+func TestWorkflowLifecycle_IsWorkflowExpired(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+			UID:       "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5",
+		},
+	}
+
+	workflow := func(name, phase string, created time.Time) *unstructured.Unstructured {
+		wf := newTestWorkflow(name, "")
+		wf.SetCreationTimestamp(metav1.NewTime(created))
+		_ = unstructured.SetNestedField(wf.Object, phase, "status", "phase")
+		return wf
+	}
+
+	now := time.Now()
+	dyn := dynfake.NewSimpleDynamicClient(sch,
+		workflow("addon-wf-old", "Running", now.Add(-3*time.Hour)),
+		workflow("addon-wf-fresh", "Running", now.Add(-time.Minute)),
+		workflow("addon-wf-old-succeeded", "Succeeded", now.Add(-3*time.Hour)),
+	)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	tests := []struct {
+		name     string
+		max      time.Duration
+		expected bool
+	}{
+		{"addon-wf-old", 2 * time.Hour, true},
+		{"addon-wf-fresh", 2 * time.Hour, false},
+		{"addon-wf-old-succeeded", 2 * time.Hour, false},
+		{"addon-wf-old", 0, false},
+	}
+
+	for _, tt := range tests {
+		expired, err := wfl.IsWorkflowExpired(context.Background(), a, tt.name, tt.max)
+		g.Expect(err).To(Not(HaveOccurred()), tt.name)
+		g.Expect(expired).To(Equal(tt.expected), tt.name)
+	}
+
+	_, err := wfl.IsWorkflowExpired(context.Background(), a, "addon-wf-missing", time.Hour)
+	g.Expect(errors.Is(err, ErrWorkflowNotFound)).To(BeTrue())
+}
+
+func TestWorkflowLifecycle_RunningDuration(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+			UID:       "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5",
+		},
+	}
+
+	now := time.Now()
+	workflow := func(name string, status map[string]interface{}) *unstructured.Unstructured {
+		wf := newTestWorkflow(name, "")
+		wf.SetCreationTimestamp(metav1.NewTime(now.Add(-time.Hour)))
+		wf.Object["status"] = status
+		return wf
+	}
+
+	dyn := dynfake.NewSimpleDynamicClient(sch,
+		workflow("addon-wf-running", map[string]interface{}{
+			"phase":     "Running",
+			"startedAt": now.Add(-10 * time.Minute).Format(time.RFC3339),
+		}),
+		workflow("addon-wf-pending", map[string]interface{}{"phase": "Pending"}),
+		workflow("addon-wf-succeeded", map[string]interface{}{
+			"phase":      "Succeeded",
+			"startedAt":  now.Add(-30 * time.Minute).Format(time.RFC3339),
+			"finishedAt": now.Add(-25 * time.Minute).Format(time.RFC3339),
+		}),
+	)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	tests := []struct {
+		name     string
+		expected time.Duration
+	}{
+		{"addon-wf-running", 10 * time.Minute},
+		{"addon-wf-pending", time.Hour},
+		{"addon-wf-succeeded", 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		d, err := wfl.RunningDuration(context.Background(), a, tt.name)
+		g.Expect(err).To(Not(HaveOccurred()), tt.name)
+		g.Expect(d).To(BeNumerically("~", tt.expected, 5*time.Second), tt.name)
+	}
+
+	d, err := wfl.RunningDuration(context.Background(), a, "addon-wf-missing")
+	g.Expect(errors.Is(err, ErrWorkflowNotFound)).To(BeTrue())
+	g.Expect(d).To(BeZero())
+}
+
+func TestWorkflowLifecycle_Install_PodGCStrategy(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, PodGCStrategy: "OnPodSuccess"}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	strategy, _, _ := unstructured.NestedString(wf.UnstructuredContent(), "spec", "podGC", "strategy")
+	g.Expect(strategy).To(Equal("OnPodSuccess"))
+
+	phase, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, PodGCStrategy: "OnFailure"}, "addon-wf-invalid")
+	g.Expect(err).To(MatchError(`invalid podGCStrategy "OnFailure"`))
+	g.Expect(phase).To(Equal(v1alpha1.Failed))
+
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get("addon-wf-invalid", metav1.GetOptions{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
+
+func TestWorkflowLifecycle_Install_PodAnnotations(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	irsa := map[string]string{"eks.amazonaws.com/role-arn": "arn:aws:iam::123456789012:role/addon"}
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, PodAnnotations: irsa}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	annotations, _, _ := unstructured.NestedStringMap(wf.UnstructuredContent(), "spec", "podMetadata", "annotations")
+	g.Expect(annotations).To(Equal(irsa))
+
+	// Template annotations take precedence
+	template := strings.Replace(wfSpecTemplate, "spec:\n", "spec:\n  podMetadata:\n    annotations:\n      eks.amazonaws.com/role-arn: template\n      team: infra\n", 1)
+	_, name, err = wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: template, PodAnnotations: irsa}, "addon-wf-merged")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	annotations, _, _ = unstructured.NestedStringMap(wf.UnstructuredContent(), "spec", "podMetadata", "annotations")
+	g.Expect(annotations).To(Equal(map[string]string{"eks.amazonaws.com/role-arn": "template", "team": "infra"}))
+}
+
+func TestWorkflowLifecycle_Install_SecurityContext(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	runAsNonRoot := true
+	fsGroup := int64(2000)
+	wt := &v1alpha1.WorkflowType{Template: wfSpecTemplate, SecurityContext: &v1.PodSecurityContext{RunAsNonRoot: &runAsNonRoot, FSGroup: &fsGroup}}
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	nonRoot, _, _ := unstructured.NestedBool(wf.UnstructuredContent(), "spec", "securityContext", "runAsNonRoot")
+	g.Expect(nonRoot).To(BeTrue())
+	group, _, _ := unstructured.NestedInt64(wf.UnstructuredContent(), "spec", "securityContext", "fsGroup")
+	g.Expect(group).To(Equal(int64(2000)))
+
+	// Nothing is injected when unset
+	_, name, err = wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-unset")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	_, found, _ := unstructured.NestedMap(wf.UnstructuredContent(), "spec", "securityContext")
+	g.Expect(found).To(BeFalse())
+}
+
+func TestWorkflowLifecycle_Install_HostAliases(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	// Template host aliases for the same IP take precedence
+	template := strings.Replace(wfSpecTemplate, "spec:\n", "spec:\n  hostAliases:\n  - ip: 10.0.0.2\n    hostnames: [git.internal]\n", 1)
+	wt := &v1alpha1.WorkflowType{
+		Template: template,
+		HostAliases: []v1.HostAlias{
+			{IP: "10.0.0.1", Hostnames: []string{"registry.internal", "charts.internal"}},
+			{IP: "10.0.0.2", Hostnames: []string{"other.internal"}},
+		},
+	}
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	hostAliases, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "hostAliases")
+	g.Expect(hostAliases).To(Equal([]interface{}{
+		map[string]interface{}{"ip": "10.0.0.2", "hostnames": []interface{}{"git.internal"}},
+		map[string]interface{}{"ip": "10.0.0.1", "hostnames": []interface{}{"registry.internal", "charts.internal"}},
+	}))
+
+	// An empty slice is a no-op
+	_, name, err = wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, HostAliases: []v1.HostAlias{}}, "addon-wf-empty")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	_, found, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "hostAliases")
+	g.Expect(found).To(BeFalse())
+}
+
+func TestWorkflowLifecycle_Install_TemplateDefaults(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	// Template defaults take precedence
+	template := strings.Replace(wfSpecTemplate, "spec:\n", "spec:\n  templateDefaults:\n    timeout: 5m\n", 1)
+	wt := &v1alpha1.WorkflowType{Template: template, TemplateDefaults: "timeout: 10m\nretryStrategy:\n  limit: 2\n"}
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, wt, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	timeout, _, _ := unstructured.NestedString(wf.UnstructuredContent(), "spec", "templateDefaults", "timeout")
+	g.Expect(timeout).To(Equal("5m"))
+	limit, found, _ := unstructured.NestedFieldNoCopy(wf.UnstructuredContent(), "spec", "templateDefaults", "retryStrategy", "limit")
+	g.Expect(found).To(BeTrue())
+	g.Expect(limit).To(BeNumerically("==", 2))
+
+	phase, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, TemplateDefaults: "- timeout"}, "addon-wf-invalid")
+	g.Expect(err).To(MatchError(ContainSubstring("invalid templateDefaults")))
+	g.Expect(phase).To(Equal(v1alpha1.Failed))
+}
+
+func TestWorkflowLifecycle_Install_OnExitTemplate(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, OnExitTemplate: "print-message"}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	onExit, _, _ := unstructured.NestedString(wf.UnstructuredContent(), "spec", "onExit")
+	g.Expect(onExit).To(Equal("print-message"))
+
+	phase, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, OnExitTemplate: "cleanup"}, "addon-wf-missing")
+	g.Expect(err).To(MatchError(`invalid onExitTemplate "cleanup", no such template in the workflow`))
+	g.Expect(phase).To(Equal(v1alpha1.Failed))
+
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get("addon-wf-missing", metav1.GetOptions{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
+
+func TestWorkflowLifecycle_Install_Synchronization(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	semaphore := &v1.ConfigMapKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "addon-installs"}, Key: "limit"}
+	tests := []struct {
+		sync     *v1alpha1.Synchronization
+		expected map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			sync: &v1alpha1.Synchronization{Semaphore: semaphore},
+			expected: map[string]interface{}{
+				"semaphore": map[string]interface{}{"configMapKeyRef": map[string]interface{}{"name": "addon-installs", "key": "limit"}},
+			},
+		},
+		{
+			sync:     &v1alpha1.Synchronization{Mutex: "addon-install"},
+			expected: map[string]interface{}{"mutex": map[string]interface{}{"name": "addon-install"}},
+		},
+		{sync: &v1alpha1.Synchronization{Semaphore: semaphore, Mutex: "addon-install"}, wantErr: true},
+		{sync: &v1alpha1.Synchronization{}, wantErr: true},
+		{sync: &v1alpha1.Synchronization{Semaphore: &v1.ConfigMapKeySelector{Key: "limit"}}, wantErr: true},
+	}
+
+	for i, tc := range tests {
+		dyn := dynfake.NewSimpleDynamicClient(sch)
+		wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+		phase, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, Synchronization: tc.sync}, "addon-wf-test")
+		if tc.wantErr {
+			g.Expect(err).To(MatchError(ContainSubstring("invalid synchronization")), fmt.Sprint(i))
+			g.Expect(phase).To(Equal(v1alpha1.Failed), fmt.Sprint(i))
+			continue
+		}
+		g.Expect(err).To(Not(HaveOccurred()), fmt.Sprint(i))
+
+		wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+		g.Expect(err).To(Not(HaveOccurred()))
+		synchronization, _, _ := unstructured.NestedMap(wf.UnstructuredContent(), "spec", "synchronization")
+		g.Expect(synchronization).To(Equal(tc.expected), fmt.Sprint(i))
+	}
+}
+
+func TestWorkflowLifecycle_Install_ImagePullPolicy(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestAddon("foo", "my-addon")
+
+	dyn := dynfake.NewSimpleDynamicClient(sch)
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	_, name, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, ImagePullPolicy: "Always"}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+
+	wf, err := dyn.Resource(common.WorkflowGVR()).Namespace("default").Get(name, metav1.GetOptions{})
+	g.Expect(err).To(Not(HaveOccurred()))
+	templates, _, _ := unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "templates")
+	policy, _, _ := unstructured.NestedString(templates[1].(map[string]interface{}), "script", "imagePullPolicy")
+	g.Expect(policy).To(Equal("Always"))
+	policy, _, _ = unstructured.NestedString(templates[2].(map[string]interface{}), "container", "imagePullPolicy")
+	g.Expect(policy).To(Equal("Always"))
+
+	// An empty policy leaves the template defaults
+	wf, err = wfl.DryRunInstall(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate}, "addon-wf-test")
+	g.Expect(err).To(Not(HaveOccurred()))
+	templates, _, _ = unstructured.NestedSlice(wf.UnstructuredContent(), "spec", "templates")
+	_, found, _ := unstructured.NestedFieldNoCopy(templates[2].(map[string]interface{}), "container", "imagePullPolicy")
+	g.Expect(found).To(BeFalse())
+
+	phase, _, err := wfl.Install(context.Background(), a, v1alpha1.Install, &v1alpha1.WorkflowType{Template: wfSpecTemplate, ImagePullPolicy: "Sometimes"}, "addon-wf-invalid")
+	g.Expect(err).To(MatchError(`invalid imagePullPolicy "Sometimes"`))
+	g.Expect(phase).To(Equal(v1alpha1.Failed))
+
+	_, err = dyn.Resource(common.WorkflowGVR()).Namespace("default").Get("addon-wf-invalid", metav1.GetOptions{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
+
+func TestWorkflowLifecycle_IsSucceeded(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := &v1alpha1.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+			UID:       "2a3cd8d3-ba3d-4a49-9a2b-6a5f0b5dd0a5",
+		},
+	}
+
+	workflow := func(name, phase string) *unstructured.Unstructured {
+		wf := newTestWorkflow(name, phase)
+		return wf
+	}
+
+	dyn := dynfake.NewSimpleDynamicClient(sch, workflow("addon-wf-succeeded", "Succeeded"), workflow("addon-wf-running", "Running"))
+	wfl := NewWorkflowLifecycle(fclient, dyn, rcdr, sch)
+
+	succeeded, err := wfl.IsSucceeded(context.Background(), a, "addon-wf-succeeded")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(succeeded).To(BeTrue())
+
+	succeeded, err = wfl.IsSucceeded(context.Background(), a, "addon-wf-running")
+	g.Expect(err).To(Not(HaveOccurred()))
+	g.Expect(succeeded).To(BeFalse())
+
+	succeeded, err = wfl.IsSucceeded(context.Background(), a, "addon-wf-missing")
+	g.Expect(errors.Is(err, ErrWorkflowNotFound)).To(BeTrue())
+	g.Expect(succeeded).To(BeFalse())
 }